@@ -4,13 +4,20 @@ import (
 	"context"
 	"log"
 	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/masallsome/masapigateway/control-plane/internal/server"
+	"github.com/masallsome/masapigateway/control-plane/pkg/aggregate"
 	serverConfig "github.com/masallsome/masapigateway/control-plane/pkg/config"
 	"github.com/masallsome/masapigateway/control-plane/pkg/k8s"
 	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	snapshotstore "github.com/masallsome/masapigateway/control-plane/pkg/snapshot"
 	"google.golang.org/grpc"
+	discoveryv1 "k8s.io/api/discovery/v1"
 )
 
 // main 是 Control Plane 服务的入口函数
@@ -53,6 +60,14 @@ func main() {
 	if err != nil {
 		// Watcher 初始化失败只打印警告，不中断程序，可能运行在无配置文件的模式下
 		log.Printf("Warning: failed to create watcher: %v", err)
+	} else {
+		// Watcher.Start() 是阻塞调用，以前由 server.AgwServer.runLoop 在内部起 goroutine 调用；
+		// 现在 AgwServer 不再持有 watcher（只依赖聚合后的 ConfigController），所以改由这里负责。
+		go func() {
+			if err := watcher.Start(); err != nil {
+				log.Printf("Watcher failed: %v", err)
+			}
+		}()
 	}
 
 	// ==========================================
@@ -60,16 +75,19 @@ func main() {
 	// ==========================================
 	ctx := context.Background()
 	
+	// 鉴权/限流参数从环境变量读取，留空时 AuthConfig.applyTo 保留 rest.Config 的默认值。
+	k8sAuth := k8sAuthConfigFromEnv()
+
 	// 初始化标准 K8s 客户端 (Clientset)
 	// 用于访问标准的 K8s 资源，如 Services, Secrets, Pods 等
-	clientset, _, err := k8s.NewClient()
+	clientset, _, err := k8s.NewClient(k8sAuth)
 	if err != nil {
 		log.Printf("Warning: failed to create K8s client: %v (K8s Discovery Disabled)", err)
 	}
-	
+
 	// 初始化动态 K8s 客户端 (DynamicClient)
 	// 用于访问自定义资源 (CRDs) 或在不知道具体类型的情况下访问资源
-	dynClient, err := k8s.NewDynamicClient()
+	dynClient, err := k8s.NewDynamicClient(k8sAuth)
 	if err != nil {
 		log.Printf("Warning: failed to create Dynamic client: %v", err)
 	}
@@ -78,43 +96,173 @@ func main() {
 	// 5. 初始化并启动 Kubernetes 控制器
 	// ==========================================
 	var k8sRegistry *k8s.Registry
+	// controllersActive 记录这个副本当前是不是真的在跑 startControllers 拉起的那一组 K8s
+	// 控制器。非选主模式下从 startControllers(ctx) 被调用起就一直是 true；选主模式下只在
+	// 持有 leader 身份期间为 true——aggregate.NewK8sSource 用它区分"还在同步中"（继续等）
+	// 和"这个副本压根没在跑"（不该继续卡住 broadcastMerged，见下方 sources 组装）。
+	var controllersActive atomic.Bool
+	// AGW_CLUSTER_ID 标识本地这一套控制器归属的集群，多集群部署时用来和
+	// AGW_MULTICLUSTER_KUBECONFIG_DIR 里接入的其它成员集群区分开；留空时退回单集群命名。
+	clusterID := os.Getenv("AGW_CLUSTER_ID")
+	ingressClass := os.Getenv("AGW_INGRESS_CLASS")
+	gatewayClass := os.Getenv("AGW_GATEWAY_CLASS")
+
 	// 只有当 K8s 客户端都成功初始化后，才启动 K8s 相关的功能
 	if clientset != nil && dynClient != nil {
 		// 初始化 K8s 注册表 (Registry)
 		// Registry 用于在其内存中存储 K8s 集群中发现的服务和配置信息
 		// Data Plane 可以通过 gRPC 接口查询这些信息
-		k8sRegistry = k8s.NewRegistry()
-		
-		// 启动 K8s 服务发现控制器 (Discovery Controller)
-		// 负责监听 K8s Service, EndpointSlice, Ingress 等资源的变化
-		// 并将最新的服务拓扑信息同步到 Registry 中
-		go func() {
-			log.Println("Starting K8s Discovery Controller...")
-			ctrl := k8s.NewController(clientset, dynClient, k8sRegistry)
-			ctrl.Run(ctx)
-		}()
-		
-		// 启动 Secret 控制器 (Secret Controller)
-		// 负责监听 K8s Secret 资源的变化（特别是 TLS 证书）
-		// 并将证书数据同步到 Registry 中，供 Data Plane 拉取用于 HTTPS 终结
-		go func() {
-			log.Println("Starting Secret Controller...")
-			ctrl := k8s.NewSecretController(clientset, k8sRegistry)
-			ctrl.Run(ctx)
-		}()
+		// AGW_PREFERRED_ADDRESS_TYPE 决定聚合 EndpointSlice 时保留哪个地址族（"IPv4" 或
+		// "IPv6"），留空时退回 IPv4；纯 IPv6 集群需要把它设成 "IPv6"，否则所有 Service 都会
+		// 聚合成没有 Endpoint 的空 Cluster。
+		k8sRegistry = k8s.NewRegistry(discoveryv1.AddressType(os.Getenv("AGW_PREFERRED_ADDRESS_TYPE")))
+
+		// startControllers 拉起所有会向 K8s 写入状态（GatewayRoute status、CRD status 等）或
+		// 占用 watch 配额的控制器。多副本部署时，如果每个副本都跑一份，会产生重复的 status
+		// 写入和重复的 reconcile；所以这一整块只应该在持有 Lease 的 leader 副本上运行，见下方
+		// AGW_LEADER_ELECT 分支。controllersCtx 由调用方决定其生命周期：非选主模式下等于进程的
+		// ctx；选主模式下是 leaderelection 在 OnStartedLeading 时交回来的、会在失去 leader 身份
+		// 时自动取消的 ctx。
+		startControllers := func(controllersCtx context.Context) {
+			// 启动 K8s 服务发现控制器 (Discovery Controller)
+			// 负责监听 K8s Service, EndpointSlice, Ingress 等资源的变化
+			// 并将最新的服务拓扑信息同步到 Registry 中
+			go func() {
+				log.Printf("Starting K8s Discovery Controller (cluster=%q)...", clusterID)
+				ctrl := k8s.NewController(clusterID, clientset, dynClient, k8sRegistry)
+				ctrl.Run(controllersCtx)
+			}()
+
+			// 启动 Secret 控制器 (Secret Controller)
+			// 负责监听 K8s Secret 资源的变化（特别是 TLS 证书）
+			// 并将证书数据同步到 Registry 中，供 Data Plane 拉取用于 HTTPS 终结
+			go func() {
+				log.Println("Starting Secret Controller...")
+				ctrl := k8s.NewSecretController(clientset, k8sRegistry)
+				ctrl.Run(controllersCtx)
+			}()
+
+			// 启动 Redis/Database 资源控制器 (Resource Controller)
+			// 负责监听 RedisResource/DatabaseResource CRD，让平台团队可以用声明式的方式
+			// 管理网关依赖的 Redis/数据库后端，而不必把它们写死在本地静态配置文件里
+			go func() {
+				log.Println("Starting Redis/Database Resource Controller...")
+				ctrl := k8s.NewResourceController(clientset, dynClient, k8sRegistry)
+				ctrl.Run(controllersCtx)
+			}()
+
+			// 启动 Ingress 控制器
+			// 让已经在用原生 networking.k8s.io/v1 Ingress 的用户不需要改写成
+			// GatewayRoute CRD 就能被这个网关发现。AGW_INGRESS_CLASS 为空时认领所有 Ingress。
+			go func() {
+				log.Printf("Starting Ingress Controller (cluster=%q, class=%q)...", clusterID, ingressClass)
+				ctrl := k8s.NewIngressController(clusterID, clientset, k8sRegistry, ingressClass)
+				ctrl.Run(controllersCtx)
+			}()
+
+			// 启动 Gateway API 控制器
+			// 翻译上游 gateway.networking.k8s.io 的 HTTPRoute/Gateway/GatewayClass。
+			// AGW_GATEWAY_CLASS 指定本网关实例对应的 GatewayClass 名字，为空时认领所有 HTTPRoute。
+			go func() {
+				log.Printf("Starting Gateway API Controller (cluster=%q, class=%q)...", clusterID, gatewayClass)
+				ctrl := k8s.NewGatewayAPIController(clusterID, dynClient, k8sRegistry, gatewayClass)
+				ctrl.Run(controllersCtx)
+			}()
+
+			// 接入额外的成员集群（可选）：AGW_MULTICLUSTER_KUBECONFIG_DIR 指向一个目录，
+			// 目录下每个文件是一份 kubeconfig，文件名即该集群的 clusterID。目录不存在时
+			// LoadFromDir 直接跳过，不影响单集群部署。
+			if mcDir := os.Getenv("AGW_MULTICLUSTER_KUBECONFIG_DIR"); mcDir != "" {
+				mc := k8s.NewMultiClusterConfig(k8sRegistry, ingressClass, gatewayClass)
+				if err := mc.LoadFromDir(controllersCtx, mcDir); err != nil {
+					log.Printf("Warning: failed to load multi-cluster kubeconfigs from %s: %v", mcDir, err)
+				}
+			}
+		}
+
+		if leaderCfg := leaderElectionConfigFromEnv(); leaderCfg.Enabled {
+			// 选主模式：只有拿到 Lease 的副本才会调用 startControllers；失去 leader 身份时，
+			// leaderelection 会取消传给 OnStartedLeading 的 ctx，startControllers 起的所有
+			// 控制器 goroutine 随之退出。本副本的 gRPC 服务器完全不受影响，继续拿 Registry
+			// 里最后一次（作为 leader 时）同步到的状态服务数据面的 StreamConfig 请求。
+			go func() {
+				onStartedLeading := func(leaderCtx context.Context) {
+					controllersActive.Store(true)
+					// leaderCtx 在失去 leader 身份时会被取消；起一个 goroutine 等它结束后
+					// 把 controllersActive 翻回 false，不然 k8sSource.Ready() 会一直以为
+					// 这个副本还在跑控制器，继续按 SourcesReady 的同步状态阻塞 broadcastMerged。
+					go func() {
+						<-leaderCtx.Done()
+						controllersActive.Store(false)
+					}()
+					startControllers(leaderCtx)
+				}
+				if err := k8s.RunLeaderElected(ctx, clientset, leaderCfg, onStartedLeading); err != nil {
+					log.Printf("Warning: leader election stopped: %v", err)
+				}
+			}()
+		} else {
+			controllersActive.Store(true)
+			startControllers(ctx)
+		}
 	}
 
 	// ==========================================
-	// 6. 初始化 gRPC 服务器
+	// 6. 聚合配置来源，初始化 gRPC 服务器
 	// ==========================================
+	// 把本地静态文件和（如果启用了）K8s Registry 都包装成 aggregate.ConfigSource，交给
+	// ConfigController 聚合。file 默认优先级更高：运维手写的静态配置和 K8s 动态发现的资源
+	// 撞名字时，静态配置赢。往这里加一个新来源（Consul、远端 xDS 上游……）不需要改
+	// AgwServer 或 broadcastMerged，只需要在这里多传一个 ConfigSource 给 NewConfigController。
+	sources := []aggregate.ConfigSource{aggregate.NewFileSource(watcher, aggregate.PriorityFile)}
+	if k8sRegistry != nil {
+		// expectedK8sSources 要跟上面 startControllers 里实际拉起的那一组控制器对上：
+		// 少列一个，Ready() 会提前报 true；多列一个不存在的名字，Ready() 会永远卡在未就绪。
+		expectedK8sSources := []string{"discovery", "secret", "resource", "ingress", "gatewayapi"}
+		sources = append(sources, aggregate.NewK8sSource(k8sRegistry, aggregate.PriorityK8s, controllersActive.Load, expectedK8sSources...))
+	}
+	controller := aggregate.NewConfigController(sources...)
+
+	// AGW_BROADCAST_DEBOUNCE 控制 runLoop 的去抖窗口：窗口内到达的多个更新信号只换来一次
+	// broadcastMerged，避免 "kubectl apply -f dir/" 这种一次产生一串 K8s 事件的操作把同一份
+	// 最终配置反复合并、反复推送。默认 200ms，设为 0 可以关闭去抖（每个信号都立刻触发广播）。
+	debounce := parseDurationEnv("AGW_BROADCAST_DEBOUNCE", 200*time.Millisecond)
+
+	// AGW_PUSH_DEADLINE 是单个 StreamConfig 客户端一次推送允许的最长耗时：超过这个期限还没
+	// 把快照发出去，就认定它是慢消费者，断开连接逼数据面重连，而不是让它永远占着一份过期配置。
+	pushDeadline := parseDurationEnv("AGW_PUSH_DEADLINE", 30*time.Second)
+
+	// AGW_SNAPSHOT_PATH 指向本地磁盘上持久化最近一次推送快照的文件；留空则完全不做持久化，
+	// 控制面重启后 s.current 要等第一次真正合并完成才会非 nil，和引入这个功能之前行为一致。
+	var snapshotStore snapshotstore.Store
+	if snapshotPath := os.Getenv("AGW_SNAPSHOT_PATH"); snapshotPath != "" {
+		snapshotStore = snapshotstore.NewFileStore(snapshotPath)
+	}
+
+	agwServer := server.NewAgwServer(controller, debounce, pushDeadline, snapshotStore)
+
 	// 创建 gRPC 服务器实例
 	s := grpc.NewServer()
-	
+
 	// 注册 AgwService 服务
-	// server.NewAgwServer 创建具体的服务实现，传入 watcher 和 k8sRegistry
-	// 这样服务实现就能获取到最新的配置和 K8s 集群信息
-	agwv1.RegisterAgwServiceServer(s, server.NewAgwServer(watcher, k8sRegistry))
-	
+	// server.NewAgwServer 创建具体的服务实现，传入聚合后的 controller
+	// 这样服务实现就能获取到最新的、合并了所有来源的配置
+	agwv1.RegisterAgwServiceServer(s, agwServer)
+
+	// AGW_ADMIN_PORT 起一个独立于 gRPC 的只读 HTTP 内省服务器，目前只挂 /debug/clients
+	// （见 internal/server/admin.go），和 Pilot 的 ControlZ//debug/edsz 是同一个用途：
+	// 运维不需要 gRPC 客户端也能看到当前连了哪些数据面、它们停在哪个版本、有没有慢消费者。
+	if adminPort := os.Getenv("AGW_ADMIN_PORT"); adminPort != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/clients", agwServer.ClientsDebugHandler())
+		go func() {
+			log.Printf("Admin HTTP server listening on port %s", adminPort)
+			if err := http.ListenAndServe(":"+adminPort, mux); err != nil {
+				log.Printf("Warning: admin HTTP server stopped: %v", err)
+			}
+		}()
+	}
+
 	// ==========================================
 	// 7. 启动服务
 	// ==========================================
@@ -124,3 +272,90 @@ func main() {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+// k8sAuthConfigFromEnv 从环境变量组装 K8s 客户端的鉴权/限流参数：
+//   - AGW_K8S_BEARER_TOKEN_FILE：周期性重新读取的 token 文件路径（OIDC/projected SA token 轮换）
+//   - AGW_K8S_QPS / AGW_K8S_BURST：覆盖 client-go 默认的 5 QPS / 10 Burst
+//   - AGW_K8S_TIMEOUT：单次请求超时，Go duration 格式（如 "10s"）
+//
+// 全部留空时返回的 AuthConfig 里所有字段都是零值，AuthConfig.applyTo 不会改动
+// getRestConfig 解析出来的默认 rest.Config，行为和引入这个配置之前完全一致。
+func k8sAuthConfigFromEnv() *k8s.AuthConfig {
+	auth := &k8s.AuthConfig{
+		BearerTokenFile: os.Getenv("AGW_K8S_BEARER_TOKEN_FILE"),
+	}
+	if qps := os.Getenv("AGW_K8S_QPS"); qps != "" {
+		if v, err := strconv.ParseFloat(qps, 32); err == nil {
+			auth.QPS = float32(v)
+		} else {
+			log.Printf("Warning: invalid AGW_K8S_QPS %q: %v", qps, err)
+		}
+	}
+	if burst := os.Getenv("AGW_K8S_BURST"); burst != "" {
+		if v, err := strconv.Atoi(burst); err == nil {
+			auth.Burst = v
+		} else {
+			log.Printf("Warning: invalid AGW_K8S_BURST %q: %v", burst, err)
+		}
+	}
+	if timeout := os.Getenv("AGW_K8S_TIMEOUT"); timeout != "" {
+		if v, err := time.ParseDuration(timeout); err == nil {
+			auth.Timeout = v
+		} else {
+			log.Printf("Warning: invalid AGW_K8S_TIMEOUT %q: %v", timeout, err)
+		}
+	}
+	return auth
+}
+
+// leaderElectionConfigFromEnv 从环境变量组装选主参数：
+//   - AGW_LEADER_ELECT：是否启用选主（"true"/"1"），默认关闭，单副本部署不需要 Lease RBAC
+//   - AGW_LEADER_ELECTION_NAMESPACE：Lease 所在的命名空间，默认 "default"
+//   - AGW_LEADER_ELECTION_LEASE_NAME：Lease 对象名字，默认 "mas-apigateway-control-plane"
+//   - AGW_LEADER_ELECTION_LEASE_DURATION / _RENEW_DEADLINE / _RETRY_PERIOD：Go duration 格式，
+//     默认值和 client-go 其它选主组件（如 kube-controller-manager）保持一致：15s/10s/2s
+func leaderElectionConfigFromEnv() k8s.LeaderElectionConfig {
+	cfg := k8s.LeaderElectionConfig{
+		Enabled:        parseBoolEnv("AGW_LEADER_ELECT", false),
+		LeaseNamespace: os.Getenv("AGW_LEADER_ELECTION_NAMESPACE"),
+		LeaseName:      os.Getenv("AGW_LEADER_ELECTION_LEASE_NAME"),
+		LeaseDuration:  parseDurationEnv("AGW_LEADER_ELECTION_LEASE_DURATION", 15*time.Second),
+		RenewDeadline:  parseDurationEnv("AGW_LEADER_ELECTION_RENEW_DEADLINE", 10*time.Second),
+		RetryPeriod:    parseDurationEnv("AGW_LEADER_ELECTION_RETRY_PERIOD", 2*time.Second),
+	}
+	if cfg.LeaseNamespace == "" {
+		cfg.LeaseNamespace = "default"
+	}
+	if cfg.LeaseName == "" {
+		cfg.LeaseName = "mas-apigateway-control-plane"
+	}
+	return cfg
+}
+
+// parseBoolEnv 解析布尔型环境变量，解析失败或未设置时返回 fallback。
+func parseBoolEnv(name string, fallback bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %v: %v", name, raw, fallback, err)
+		return fallback
+	}
+	return v
+}
+
+// parseDurationEnv 解析 Go duration 格式的环境变量，解析失败或未设置时返回 fallback。
+func parseDurationEnv(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using default %s: %v", name, raw, fallback, err)
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ClientsDebugHandler 实现一个类似 Istio Pilot /debug/edsz、ControlZ 的只读内省端点：
+// 列出所有当前连接的 StreamConfig 客户端、它们各自最后发送成功的版本号、最后一次错误
+// （通常是慢消费者被断开的原因）以及是否还有一份快照积压在 pending 里没发出去。
+// main.go 把它挂在一个独立的 admin HTTP 服务器上，不和 gRPC 端口混用。
+func (s *AgwServer) ClientsDebugHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		infos := make([]clientDebugInfo, 0, len(s.clients))
+		for _, p := range s.clients {
+			infos = append(infos, p.describe())
+		}
+		s.mu.RUnlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(infos); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/masallsome/masapigateway/control-plane/pkg/config"
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// 四种参与 delta 推送的资源类型，风格上沿用 xDS 的 type_url 约定（虽然这里不走真正的
+// google.protobuf.Any 注册表，只是把它当成一个稳定的分类前缀）。
+const (
+	typeURLListener = "type.googleapis.com/agw.v1.Listener"
+	typeURLRoute    = "type.googleapis.com/agw.v1.Route"
+	typeURLCluster  = "type.googleapis.com/agw.v1.Cluster"
+	typeURLSecret   = "type.googleapis.com/agw.v1.TlsConfig"
+)
+
+// deltaNackTotal 统计数据面通过 DeltaRequest.error_detail 拒绝（NACK）的推送次数，
+// 让运维能从 Prometheus 告警，而不用去翻控制面日志才发现配置被数据面拒收。
+var deltaNackTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "agw_control_plane_delta_nack_total",
+	Help: "Total number of DeltaRequest messages received with a non-empty error_detail (NACK).",
+})
+
+// deltaEntry 是参与 delta 比对的单个资源：它的复合键（见 key()）、内容哈希（作为版本号）
+// 以及要打包进 DeltaResponse 的 proto 消息本体。
+type deltaEntry struct {
+	typeURL string
+	name    string
+	version string
+	msg     proto.Message
+}
+
+// key 返回这个资源在 initial_resource_versions / deltaClient.acked 里使用的复合键。
+// 之所以把 type_url 拼进 name 而不是像真正的 ADS 那样按类型分开一张表，是因为这里把
+// Listener/Route/Cluster/Secret 四类资源合并进同一条 DeltaResponse 推送（数据面总是要
+// 一起用它们），带单独的类型表只会让客户端断线重连时多一份簿记，收益不大。
+func (e deltaEntry) key() string {
+	return e.typeURL + "/" + e.name
+}
+
+// computeDeltaEntries 把一份合并后的 ConfigSnapshot 拆解成参与 delta 比对的资源清单。
+// 每个资源用 config.HashResource 单独打版本号，这样 pushDelta 才能知道具体是哪些资源变了，
+// 而不必像 StreamConfig 那样每次都把整份快照重新发一遍。
+func computeDeltaEntries(snapshot *agwv1.ConfigSnapshot) []deltaEntry {
+	entries := make([]deltaEntry, 0, len(snapshot.Listeners)+len(snapshot.Routes)+len(snapshot.Clusters))
+
+	for _, l := range snapshot.Listeners {
+		entries = append(entries, deltaEntry{typeURL: typeURLListener, name: l.Name, version: config.HashResource(l), msg: l})
+
+		// TLS 证书/私钥已经被 broadcastMerged 注入到 l.Tls 里了，单独作为一个 Secret 资源
+		// 打版本号：证书轮换时只有它的哈希会变，不应该导致整个 Listener 被重新推送一遍。
+		if l.Tls != nil && l.Tls.SecretName != "" && (len(l.Tls.CertPem) > 0 || len(l.Tls.KeyPem) > 0) {
+			entries = append(entries, deltaEntry{typeURL: typeURLSecret, name: l.Tls.SecretName, version: config.HashResource(l.Tls), msg: l.Tls})
+		}
+	}
+
+	for i, r := range snapshot.Routes {
+		entries = append(entries, deltaEntry{typeURL: typeURLRoute, name: config.RouteName(r, i), version: config.HashResource(r), msg: r})
+	}
+
+	for _, c := range snapshot.Clusters {
+		entries = append(entries, deltaEntry{typeURL: typeURLCluster, name: c.Name, version: config.HashResource(c), msg: c})
+	}
+
+	return entries
+}
+
+// resourceTypeHashes 把 entries 按 typeURL 分组，对每一组里 "name:version" 排序后拼接出的
+// 字符串再取一次哈希，得到每种资源类型各自的一个稳定摘要。runLoop 的去抖层拿它和上一次
+// 真正推送时的摘要比较：四个类型的摘要都没变，说明这一轮合并出来的配置和已经推给数据面的
+// 完全一样（哪怕触发合并的事件本身是真实的），可以直接抑制这次推送。
+func resourceTypeHashes(entries []deltaEntry) map[string]string {
+	byType := make(map[string][]string)
+	for _, e := range entries {
+		byType[e.typeURL] = append(byType[e.typeURL], e.name+":"+e.version)
+	}
+
+	hashes := make(map[string]string, len(byType))
+	for typeURL, parts := range byType {
+		sort.Strings(parts)
+		hashes[typeURL] = config.GenerateVersion([]byte(strings.Join(parts, ",")))
+	}
+	return hashes
+}
+
+// deltaClient 保存单个 StreamConfigDelta 连接的服务端状态。
+type deltaClient struct {
+	updates chan struct{} // "有更新了" 的信号，broadcastMerged 之后由 broadcastDeltaNotify 非阻塞写入
+
+	// acked 是这个客户端已确认（ACK 过，或者重连时通过 initial_resource_versions 告知）
+	// 的资源版本表，key 是 deltaEntry.key()。pushDelta 只需要和这张表比较，而不是和
+	// "已发送但还没确认" 的状态比较——这样即使客户端迟迟不回 ACK，服务端也不会停止补发。
+	acked map[string]string
+
+	// pending 是上一次 pushDelta 发出去、还没被对应 nonce 的 ACK 确认的资源版本。
+	// 收到匹配 pendingNonce 的 DeltaRequest（error_detail 为空）时合并进 acked；
+	// 收到 NACK 则原样保留，下一次 pushDelta 会把它们当成仍未送达重新计算。
+	pending      map[string]string
+	pendingNonce string
+	nonce        int64
+}
+
+// nodeID 从 DeltaRequest.Node 里取出标识，Node 为空时不应该让日志打印 panic。
+func nodeID(n *agwv1.Node) string {
+	if n == nil {
+		return "unknown"
+	}
+	return n.Id
+}
+
+// registerDeltaClient 注册一个新的 StreamConfigDelta 连接，复用 AgwServer 的 nextID 序列，
+// 这样 delta 客户端和 StreamConfig 客户端的 ID 不会撞在一起，日志里也好区分排查。
+func (s *AgwServer) registerDeltaClient(notify chan struct{}) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.deltaClients[id] = &deltaClient{
+		updates: notify,
+		acked:   make(map[string]string),
+	}
+	return id
+}
+
+// unregisterDeltaClient 在连接断开时清理对应的 delta 状态。
+func (s *AgwServer) unregisterDeltaClient(id int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.deltaClients, id)
+}
+
+// broadcastDeltaNotify 唤醒所有当前连接的 delta 客户端，调用方（broadcastMerged）必须已经
+// 持有 s.mu：这里只做 map 遍历和非阻塞 channel 发送，不会阻塞。
+func (s *AgwServer) broadcastDeltaNotify() {
+	for _, dc := range s.deltaClients {
+		select {
+		case dc.updates <- struct{}{}:
+		default:
+			// 已经有一个待处理的唤醒信号了，没必要重复塞；pushDelta 本身是幂等的，
+			// 会重新和当前的 currentEntries 做全量比对，不会丢更新。
+		}
+	}
+}
+
+// handleDeltaRequest 处理客户端发来的一条 DeltaRequest：可能是 ACK、NACK，或者首次连接/
+// 重连时携带的 initial_resource_versions。
+func (s *AgwServer) handleDeltaRequest(id int64, req *agwv1.DeltaRequest) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dc, ok := s.deltaClients[id]
+	if !ok {
+		return
+	}
+
+	if req.ErrorDetail != "" {
+		// NACK：数据面明确拒绝了上一次推送（通常是配置校验失败）。记一个 Prometheus 计数器 +
+		// 一条警告日志，但**不要**把 dc.pending 合并进 acked——否则下一次 diff 会认为这些
+		// 资源已经确认生效，不会再尝试重新推送，数据面就会永远停在一份被拒绝的配置上。
+		deltaNackTotal.Inc()
+		log.Printf("Warning: node %s NACKed delta config (nonce=%s): %s", nodeID(req.Node), req.ResponseNonce, req.ErrorDetail)
+		return
+	}
+
+	if req.ResponseNonce != "" && req.ResponseNonce == dc.pendingNonce {
+		for k, v := range dc.pending {
+			dc.acked[k] = v
+		}
+		dc.pending = nil
+	}
+
+	// 首次连接或断线重连：客户端通过 initial_resource_versions 告诉我们它本地缓存里还留着
+	// 哪些资源，这样下一次 pushDelta 只会补发真正变化过的部分，不用重新搬一遍全量配置。
+	for k, v := range req.InitialResourceVersions {
+		dc.acked[k] = v
+	}
+}
+
+// pushDelta 把当前 currentEntries 和客户端已知的 acked 状态做 diff，只在真的有变化时发送
+// 一条 DeltaResponse。调用方负责处理 stream.Send 返回的错误（通常意味着连接已经断开）。
+func (s *AgwServer) pushDelta(id int64, stream grpc.BidiStreamingServer[agwv1.DeltaRequest, agwv1.DeltaResponse]) error {
+	s.mu.Lock()
+	dc, ok := s.deltaClients[id]
+	if !ok || s.current == nil {
+		s.mu.Unlock()
+		return nil
+	}
+
+	current := make(map[string]string, len(s.currentEntries))
+	var changed []*agwv1.Resource
+	for _, e := range s.currentEntries {
+		current[e.key()] = e.version
+		if dc.acked[e.key()] == e.version {
+			continue // 客户端已经有这份内容了，跳过
+		}
+		payload, err := anypb.New(e.msg)
+		if err != nil {
+			log.Printf("Warning: failed to pack delta resource %s: %v", e.key(), err)
+			continue
+		}
+		changed = append(changed, &agwv1.Resource{Name: e.key(), Version: e.version, Resource: payload})
+	}
+
+	// 对客户端已知（acked）但已经从当前配置里消失的资源，以及上一次推送了但还没确认、
+	// 现在也已经消失的资源，统一归进 removed_names，否则客户端不知道该把它们删掉。
+	removedSet := make(map[string]struct{})
+	for k := range dc.acked {
+		if _, ok := current[k]; !ok {
+			removedSet[k] = struct{}{}
+		}
+	}
+	for k := range dc.pending {
+		if _, ok := current[k]; !ok {
+			removedSet[k] = struct{}{}
+		}
+	}
+	removed := make([]string, 0, len(removedSet))
+	for k := range removedSet {
+		removed = append(removed, k)
+	}
+
+	if len(changed) == 0 && len(removed) == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+
+	dc.nonce++
+	nonce := strconv.FormatInt(dc.nonce, 10)
+	dc.pendingNonce = nonce
+	pending := make(map[string]string, len(changed))
+	for _, r := range changed {
+		pending[r.Name] = r.Version
+	}
+	dc.pending = pending
+	systemVersion := s.current.VersionId
+	s.mu.Unlock()
+
+	return stream.Send(&agwv1.DeltaResponse{
+		Resources:            changed,
+		RemovedResourceNames: removed,
+		Nonce:                nonce,
+		SystemVersion:        systemVersion,
+	})
+}
+
+// StreamConfigDelta 是增量 (delta) xDS 风格的 gRPC 接口：相比 StreamConfig 每次都推一份完整
+// ConfigSnapshot，这里只推送自客户端上次确认以来真正变化过的资源，并支持客户端通过
+// error_detail 拒绝（NACK）一次推送。
+func (s *AgwServer) StreamConfigDelta(stream grpc.BidiStreamingServer[agwv1.DeltaRequest, agwv1.DeltaResponse]) error {
+	log.Println("New delta stream connected")
+
+	notify := make(chan struct{}, 1)
+	id := s.registerDeltaClient(notify)
+	defer s.unregisterDeltaClient(id)
+
+	// Recv 是阻塞调用，单独起一个 goroutine 搬进 channel，这样下面的 select 才能同时
+	// 等待 "客户端发来新请求" 和 "配置有更新" 两类事件，和 StreamConfig 的结构保持一致。
+	//
+	// reqCh 带 1 个缓冲，并且发送时也 select 了 stream.Context().Done()：下面的主 select
+	// 可能已经因为 pushDelta 出错或者 ctx 被取消而返回了，这个 goroutine 的下一次 Recv
+	// 仍然可能正好在那之前拿到一个请求——没有缓冲 + 无条件阻塞发送的话，这个 goroutine
+	// 会永远卡在 `reqCh <- req` 上，defer wg.Wait() 也跟着永远不返回，每断开一个连接就
+	// 泄漏一个 goroutine。
+	reqCh := make(chan *agwv1.DeltaRequest, 1)
+	errCh := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				select {
+				case errCh <- err:
+				case <-stream.Context().Done():
+				}
+				return
+			}
+			select {
+			case reqCh <- req:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+	defer wg.Wait()
+
+	for {
+		select {
+		case req := <-reqCh:
+			s.handleDeltaRequest(id, req)
+			if err := s.pushDelta(id, stream); err != nil {
+				log.Printf("Error sending delta to node %s: %v", nodeID(req.Node), err)
+				return err
+			}
+
+		case <-notify:
+			if err := s.pushDelta(id, stream); err != nil {
+				log.Println("Error sending delta update:", err)
+				return err
+			}
+
+		case err := <-errCh:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
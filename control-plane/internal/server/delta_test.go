@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeDeltaStream is a minimal grpc.BidiStreamingServer[DeltaRequest, DeltaResponse] stand-in
+// that only records what gets Send'd — pushDelta never calls the other ServerStream methods.
+type fakeDeltaStream struct {
+	ctx  context.Context
+	sent []*agwv1.DeltaResponse
+}
+
+func (f *fakeDeltaStream) Send(resp *agwv1.DeltaResponse) error {
+	f.sent = append(f.sent, resp)
+	return nil
+}
+func (f *fakeDeltaStream) Recv() (*agwv1.DeltaRequest, error) { <-f.ctx.Done(); return nil, f.ctx.Err() }
+func (f *fakeDeltaStream) Context() context.Context           { return f.ctx }
+func (f *fakeDeltaStream) SendMsg(m interface{}) error        { return nil }
+func (f *fakeDeltaStream) RecvMsg(m interface{}) error        { return nil }
+func (f *fakeDeltaStream) SetHeader(metadata.MD) error        { return nil }
+func (f *fakeDeltaStream) SendHeader(metadata.MD) error       { return nil }
+func (f *fakeDeltaStream) SetTrailer(metadata.MD)             {}
+
+// TestPushDeltaReportsRemovedNames 覆盖 pushDelta 的 removed_names 计算：一个资源先被推送、
+// 被客户端 ACK，然后从下一轮合并结果里消失，必须出现在下一次 DeltaResponse.RemovedResourceNames
+// 里，否则数据面永远不知道该把它删掉（见 delta.go pushDelta 里 removedSet 的构造逻辑）。
+func TestPushDeltaReportsRemovedNames(t *testing.T) {
+	s := &AgwServer{deltaClients: make(map[int64]*deltaClient)}
+	id := s.registerDeltaClient(make(chan struct{}, 1))
+
+	listener := &agwv1.Listener{Name: "l1", Address: "0.0.0.0", Port: 80}
+	withListener := &agwv1.ConfigSnapshot{Listeners: []*agwv1.Listener{listener}, VersionId: "v1"}
+	s.current = withListener
+	s.currentEntries = computeDeltaEntries(withListener)
+	removedKey := s.currentEntries[0].key()
+
+	stream := &fakeDeltaStream{ctx: context.Background()}
+	if err := s.pushDelta(id, stream); err != nil {
+		t.Fatalf("pushDelta: %v", err)
+	}
+	if len(stream.sent) != 1 || len(stream.sent[0].Resources) != 1 {
+		t.Fatalf("expected one push carrying the listener, got %+v", stream.sent)
+	}
+
+	// 模拟数据面 ACK 这次推送。
+	s.handleDeltaRequest(id, &agwv1.DeltaRequest{ResponseNonce: stream.sent[0].Nonce})
+
+	// 下一轮合并里这个 Listener 消失了。
+	empty := &agwv1.ConfigSnapshot{VersionId: "v2"}
+	s.current = empty
+	s.currentEntries = computeDeltaEntries(empty)
+
+	if err := s.pushDelta(id, stream); err != nil {
+		t.Fatalf("pushDelta after removal: %v", err)
+	}
+	if len(stream.sent) != 2 {
+		t.Fatalf("expected a second push reporting the removal, got %d pushes", len(stream.sent))
+	}
+	removed := stream.sent[1].RemovedResourceNames
+	if len(removed) != 1 || removed[0] != removedKey {
+		t.Fatalf("expected removed_names = [%q], got %v", removedKey, removed)
+	}
+}
@@ -3,162 +3,213 @@ package server
 import (
 	"fmt"
 	"log"
+	"maps"
 	"sync"
 	"time"
 
+	"github.com/masallsome/masapigateway/control-plane/pkg/aggregate"
 	"github.com/masallsome/masapigateway/control-plane/pkg/config"
-	"github.com/masallsome/masapigateway/control-plane/pkg/k8s"
 	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	// 这个包里到处都用局部变量名 snapshot 指代一份 *agwv1.ConfigSnapshot，所以持久化包
+	// 用 snapshotstore 这个别名导入，避免互相遮蔽。
+	snapshotstore "github.com/masallsome/masapigateway/control-plane/pkg/snapshot"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 )
 
 type AgwServer struct {
 	// 继承 UnimplementedAgwServiceServer 以保证向前兼容性
 	agwv1.UnimplementedAgwServiceServer
-	
-	watcher      *config.Watcher // 监听本地静态配置文件
-	registry     *k8s.Registry   // 监听 K8s 动态资源 (CRD, Secret)
-	
+
+	// controller 聚合了所有配置来源（本地静态文件、K8s CRD……），broadcastMerged 只跟它打
+	// 交道，不再关心具体有哪些来源、它们各自怎么实现——新增一个来源只需要在 main.go 里把它
+	// 传给 aggregate.NewConfigController，不需要改这个文件。
+	controller *aggregate.ConfigController
+
 	mu           sync.RWMutex    // 读写锁，保护下面的 clients 映射表
-	
+
 	// clients 维护了所有当前连接的数据平面 (Data Plane) 实例。
 	// Key: int64 (nextID 生成的唯一连接 ID)
-	// Value: chan *agwv1.ConfigSnapshot (发送配置快照的管道)
+	// Value: *clientPusher，每个连接专属一个发送循环（见 pusher.go）
 	//
-	// 【为什么要用 chan?】
-	// 1. **解耦发送与处理**：控制平面生成新配置后，只需往管道里“丢”一份快照即可，不需要等待网络发送完成。
-	// 2. **异步广播**：当配置变更时，我们可以遍历所有 clients，通过 channel 并发地把新配置推给每一个连接，而不会因为某个连接网络卡顿而阻塞整个控制平面的更新流程。
-	// 3. **作为缓冲区**：如果数据平面处理慢，channel 可以起到微小的缓冲作用（虽然这里大多是一次性推送）。
-	clients      map[int64]chan *agwv1.ConfigSnapshot
-	
+	// 【为什么不直接用 chan？】早期版本是 chan *agwv1.ConfigSnapshot + 非阻塞发送，
+	// 一旦某个客户端的 1 缓冲 channel 被占满，后续更新就被无声丢弃，那个数据面会一直停留
+	// 在旧配置上直到下一次全局变更才有机会追上。clientPusher 改成"覆盖 pending 快照 +
+	// 专属发送 goroutine + 发送超时"，既保证只发最新配置，又能在客户端长期不读的时候
+	// 主动断开而不是静默卡住或丢数据。
+	clients      map[int64]*clientPusher
+
+	// deltaClients 维护了所有通过 StreamConfigDelta 连接的数据平面实例的增量推送状态。
+	// 和 clients 不同，这里每个连接记的不是"下一份要发的快照"，而是这个客户端目前已知
+	// （已 ACK 或者重连时通过 initial_resource_versions 告知）的每个资源的版本号，
+	// 这样才能在 broadcastMerged 之后只补发真正变化过的资源。详见 delta.go。
+	deltaClients map[int64]*deltaClient
+
 	nextID       int64                  // 用于生成下一个 client 的唯一 ID
 	current      *agwv1.ConfigSnapshot  // 当前最新的、已合并的全局配置快照 (缓存)
-	staticConfig *agwv1.ConfigSnapshot  // 从本地文件加载的静态配置 (作为基底)
+	// currentEntries 是 current 按 Listener/Route/Cluster/Secret 拆解出的带版本号的资源清单，
+	// 与 current 在同一次 broadcastMerged 里一起重新计算，供 delta 推送按资源比对差异。
+	currentEntries []deltaEntry
+	// lastTypeHashes 是上一次真正推送出去的配置按资源类型算出的摘要（见 resourceTypeHashes）。
+	// broadcastMerged 每次都会和它比较，四个类型的摘要都没变就说明这一轮合并结果和已经推给
+	// 数据面的完全一样，直接抑制这次推送，不浪费一次网络广播。
+	lastTypeHashes map[string]string
+
+	// debounce 是 runLoop 对 controller 更新信号的去抖窗口：窗口内到达的后续信号只会重置计时器、
+	// 不会各自触发一次 broadcastMerged，这样 "kubectl apply -f dir/" 这种一次性产生一串 K8s
+	// 事件的操作，最终只换来一次合并和一次推送。
+	debounce time.Duration
+
+	// pushDeadline 是单个 StreamConfig 客户端一次 Send 允许的最长耗时（见 pusher.go 的
+	// clientPusher.run）；超时就判定为慢消费者并断开，而不是让它永远占着一份过期配置。
+	pushDeadline time.Duration
+
+	// snapshotStore 持久化每一次真正推送出去的快照，供下次进程重启后热启动用（见
+	// NewAgwServer 和 broadcastMerged 末尾的 Save 调用）。nil 表示没配置持久化路径，
+	// 完全退回重启前的行为：s.current 在第一次真正合并完成之前都是 nil。
+	snapshotStore snapshotstore.Store
 }
 
-func NewAgwServer(watcher *config.Watcher, registry *k8s.Registry) *AgwServer {
+// NewAgwServer 创建一个由 controller 驱动的 AgwServer。controller 聚合了无论多少个配置
+// 来源（file、k8s……），AgwServer 不再需要知道具体来源长什么样，只管消费它聚合后的结果。
+// debounce 是去抖窗口，<= 0 时退化成不去抖（每个信号都立刻触发一次合并）。pushDeadline 是
+// 慢消费者断线的判定期限。store 为 nil 时不做快照持久化/热启动，和引入这个参数之前的行为
+// 完全一致；非 nil 时，构造函数会立刻尝试 Load 一份快照填进 s.current，让 registerClient
+// 在 informer/watcher 还没完成首次同步的这段窗口期也能应答新连接的数据面。
+func NewAgwServer(controller *aggregate.ConfigController, debounce time.Duration, pushDeadline time.Duration, store snapshotstore.Store) *AgwServer {
 	s := &AgwServer{
-		watcher:  watcher,
-		registry: registry,
-		clients:  make(map[int64]chan *agwv1.ConfigSnapshot),
+		controller:    controller,
+		clients:       make(map[int64]*clientPusher),
+		deltaClients:  make(map[int64]*deltaClient),
+		debounce:      debounce,
+		pushDeadline:  pushDeadline,
+		snapshotStore: store,
+	}
+
+	if store != nil {
+		cached, err := store.Load()
+		if err != nil {
+			log.Printf("Warning: failed to load cached snapshot: %v", err)
+		} else if cached != nil {
+			log.Printf("Warm-started from cached snapshot version %s", cached.VersionId)
+			s.current = cached
+			s.currentEntries = computeDeltaEntries(cached)
+		}
 	}
+
 	// Start loop
 	go s.runLoop()
 	return s
 }
 
+// runLoop 是控制平面的主事件循环：聚合器说有来源变了，不会立刻合并，而是起/重置一个
+// debounce 定时器，只有定时器真正到期（这个窗口里再也没有新信号进来）才调用 broadcastMerged。
+// 具体是文件变了还是 K8s 变了（或者两者都变了），controller 内部已经吸收掉了，这里不需要
+// 再区分——这正是引入 ConfigController 想要达到的效果。
 func (s *AgwServer) runLoop() {
-	go func() {
-		if err := s.watcher.Start(); err != nil {
-			log.Printf("Watcher failed: %v", err)
-		}
-	}()
-	
-	// Initial empty static config to avoid nil
-	// 初始化静态配置快照，默认为空，等待第一次加载
-	s.staticConfig = &agwv1.ConfigSnapshot{VersionId: "init"}
-
-	// 获取两个关键的事件通知通道：
-	// 1. registryCh: 监听 K8s 动态资源 (CRD, Secret, Service) 的变更信号
-	// 2. watcherCh:  监听本地静态配置文件 (config.yaml) 的内容变更
-	var registryCh <-chan struct{}
-	if s.registry != nil {
-		registryCh = s.registry.Updates()
-	}
-	watcherCh := s.watcher.Updates()
-	
-	// 启动【控制平面主事件循环】(Main Event Loop)
-	// 这里的 select 类似于多路复用器，同时等待来自两个方向的变更通知。
+	updates := s.controller.Updates()
+
+	var timerCh <-chan time.Time
+	var timer *time.Timer
+	pending := false // 窗口内是否已经吃到过至少一个信号，决定计时器到期后要不要真的合并
+
 	for {
 		select {
-		// 情况 A: 本地静态配置文件变了
-		case snapshot, ok := <-watcherCh:
-			if !ok {
-				log.Println("Watcher channel closed, stopping runLoop")
-				return // 通道关闭，退出循环 (通常是程序关闭时)
-			}
-			// 更新内存中的静态配置基底
-			s.staticConfig = snapshot
-			// 触发合并广播：静态配置 + 动态 K8s 配置
-			s.broadcastMerged()
-
-		// 情况 B: K8s 里的资源变了 (Registry 发出了信号)
-		case _, ok := <-registryCh:
-			// 注意：如果 registryCh 为 nil (即 K8s 未启用)，select 会永远忽略这个 case，这是安全的。
+		case _, ok := <-updates:
 			if !ok {
-				log.Println("Registry channel closed")
+				log.Println("Config controller updates channel closed, stopping runLoop")
 				return
 			}
-			// 触发合并广播
-			s.broadcastMerged()
+			if s.debounce <= 0 {
+				s.broadcastMerged()
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(s.debounce)
+				timerCh = timer.C
+			} else {
+				// 窗口内又来了一个信号：把它合并掉（重置计时器），不单独触发一次广播。
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(s.debounce)
+				debounceCoalescedTotal.Inc()
+			}
+			pending = true
+
+		case <-timerCh:
+			timer = nil
+			timerCh = nil
+			if pending {
+				pending = false
+				s.broadcastMerged()
+			}
 		}
 	}
 }
 
-// broadcastMerged 将 "静态配置" 和 "动态 K8s 配置" 合并成一份最终配置，
-// 然后推送给所有连接的数据平面客户端。
+// broadcastMerged 从 controller 里拉取所有来源的最新状态、合并成一份 ConfigSnapshot。
+// 如果合并结果按资源类型算出的摘要和上一次真正推送的完全一样，直接抑制这次推送；
+// 否则推送给所有连接的数据平面客户端，并记录推送延迟。
 func (s *AgwServer) broadcastMerged() {
+	// 0. 还没就绪的来源（典型情况是 K8s Informer 还没完成首次 cache sync）不应该参与合并：
+	// 这时候 ConfigController.ListXXX() 读到的只是集群状态的一部分，把这种"半同步"的结果
+	// 发布出去，数据面会先拿到一份残缺配置，过几秒又被覆盖成完整的，没有意义还会造成抖动。
+	// runLoop 的信号本来就是 "来源说它变了"，跳过这一轮之后，sources ready 之后的下一次
+	// 事件（或者 resyncPeriod 兜底触发的事件）会重新尝试。
+	if !s.controller.Ready() {
+		log.Println("Skipping broadcast: config sources are not ready yet (still syncing)")
+		return
+	}
+
+	start := time.Now()
+
+	// 1. 从聚合器里按 (来源优先级, 资源名) 取出一份已经去重、排好序的资源清单。
+	// 冲突检测（同名资源出现在多个来源）由 ConfigController 负责记日志，这里不用关心。
+	listeners := s.controller.ListListeners()
+	routes := s.controller.ListRoutes()
+	clusters := s.controller.ListClusters()
+	resources := s.controller.ListResources()
+
 	// 加锁，确保在生成快照的过程中，不会有新的客户端连接进来干扰，保证线程安全
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	// 1. 获取本地静态配置的基底
-	staticCfg := s.staticConfig 
-
-	// 准备 K8s 数据 (如果 Registry 存在)
-	var k8sRoutes []*agwv1.Route
-	var k8sClusters []*agwv1.Cluster
-	if s.registry != nil {
-		k8sRoutes = s.registry.ListRoutes()
-		k8sClusters = s.registry.ListClusters()
-	}
 
-	// 2. 创建一个新的配置快照对象 (Snapshot)，开始【合并】逻辑
+	// 2. 创建一个新的配置快照对象 (Snapshot)
 	snapshot := &agwv1.ConfigSnapshot{
-		Listeners: staticCfg.Listeners, // 暂时先引用静态 Listeners (后面会处理 TLS 证书注入)
-		// 【合并路由】：将静态文件的 Routes 和 K8s Registry 里的 CRD Routes 拼接到一起
-		// append(A, B...) 语法将 B 切片打散追加到 A 后面
-		Routes: append(staticCfg.Routes, k8sRoutes...),
-		// 【合并集群】：先放入静态集群 (通常为空或测试用)
-		Clusters: staticCfg.Clusters,
-		// 【合并资源】：Redis 和数据库配置 (直接引用静态配置，因为目前 K8s 侧没有对应 CRD)
-		Resources: staticCfg.Resources,
+		Listeners: listeners, // 暂时先引用合并后的 Listeners (后面会处理 TLS 证书注入)
+		Routes:    routes,
+		Clusters:  clusters,
+		Resources: resources,
 	}
 
-	// 继续追加 K8s 中发现的服务集群 (EndpointSlices 转换而来)
-	snapshot.Clusters = append(snapshot.Clusters, k8sClusters...)
-	
 	// 3. 【注入 TLS 证书】 (Resolve Secrets)
 	// 这一步非常关键：因为 Proto 定义里的 SecretName 只是一个字符串引用，
 	// 数据面 Data Plane 需要真正的证书内容 (PEM 格式) 才能启动 HTTPS。
-	// 我们需要遍历所有 Listener，如果发现它引用了 Secret，就去 Registry 里把 Secret 内容挖出来填进去。
-	
-	// 创建一个新的 Listener 切片，容量与静态配置一致
-	newListeners := make([]*agwv1.Listener, 0, len(staticCfg.Listeners))
-	for _, l := range staticCfg.Listeners {
+	// 我们需要遍历所有 Listener，如果发现它引用了 Secret，就去 controller 里把 Secret 内容挖出来填进去。
+
+	// 创建一个新的 Listener 切片，容量与合并结果一致
+	newListeners := make([]*agwv1.Listener, 0, len(listeners))
+	for _, l := range listeners {
 		// 浅拷贝 (Shallow Copy) Listener 结构体本身
-		// 为什么？因为我们即将修改里面的 Tls 字段。如果不拷贝直接改，会污染 s.staticConfig 原本的数据，
+		// 为什么？因为我们即将修改里面的 Tls 字段。如果不拷贝直接改，会污染来源自己持有的数据，
 		// 导致下次合并时逻辑出错。
 		nl := *l
-		
+
 		// 如果该监听器开启了 TLS 并且指定了 Secret 名字
 		if nl.Tls != nil && nl.Tls.SecretName != "" {
-			// 去 Registry 查找这是不是一个已经缓存的 K8s Secret
-			// 只有当 Registry 启用时才去查找
-			var secret *k8s.TlsSecret
-			if s.registry != nil {
-				secret = s.registry.GetSecret(nl.Tls.SecretName)
-			}
+			// 向聚合器查询这个名字对应的 Secret，它会按优先级问遍所有实现了 GetSecret 的来源
+			secret := s.controller.GetSecret(nl.Tls.SecretName)
 
 			if secret != nil {
 				// 同样，我们需要深拷贝 TlsConfig，避免修改原始指针指向的对象
 				newTls := *nl.Tls
-				// 【核心动作】：把 K8s Secret 里存的证书内容 (Cert/Key) 填充到配置对象里
+				// 【核心动作】：把 Secret 里存的证书内容 (Cert/Key) 填充到配置对象里
 				newTls.CertPem = secret.Cert
 				newTls.KeyPem = secret.Key
 				nl.Tls = &newTls // 指向新的包含了证书内容的 TlsConfig
 			} else {
-				log.Printf("Warning: Secret %s not found for listener %s (Registry capable: %v)", nl.Tls.SecretName, nl.Name, s.registry != nil)
+				log.Printf("Warning: Secret %s not found for listener %s", nl.Tls.SecretName, nl.Name)
 			}
 		}
 		// 将处理好的（可能注入了证书的）Listener 加入新列表
@@ -167,50 +218,80 @@ func (s *AgwServer) broadcastMerged() {
 	// 用处理好的 Listener 列表替换快照里的旧列表
 	snapshot.Listeners = newListeners
 
-	// 4. 生成新版本号
-	// 格式：静态版本-k8s-当前时间戳。这样数据面可以知道配置是否更新。
-	version := fmt.Sprintf("%s-k8s-%s", staticCfg.VersionId, time.Now().Format("150405"))
-	snapshot.VersionId = version 
+	// 4. 按资源类型算一份摘要，和上一次真正推送的摘要比较。完全一样就说明这一轮合并
+	// （哪怕是被某个真实事件触发的）产出的配置和数据面已经有的没有任何区别，直接抑制，
+	// 不生成新版本号也不广播——这是避免滚动升级时 flapping 的关键一步。
+	entries := computeDeltaEntries(snapshot)
+	typeHashes := resourceTypeHashes(entries)
+	if s.lastTypeHashes != nil && maps.Equal(typeHashes, s.lastTypeHashes) {
+		pushesSuppressedTotal.Inc()
+		log.Println("Suppressing broadcast: merged snapshot is identical to the last one pushed")
+		return
+	}
+	s.lastTypeHashes = typeHashes
+
+	// 5. 生成新版本号：对合并后的快照内容取哈希，拼上时间戳，这样数据面能判断配置是否更新，
+	// 同时同样内容的两次合并（没有任何来源真正变化）会得到同一个哈希前缀，便于排查。
+	version := fmt.Sprintf("agg-%s-%s", hashSnapshot(snapshot), time.Now().Format("150405"))
+	snapshot.VersionId = version
 
 	// 更新服务器持有的最新快照
-	s.current = snapshot 
+	s.current = snapshot
+	// 同步保存这一轮的 delta 资源清单：每个 Listener/Route/Cluster/Secret 单独打一个内容哈希，
+	// StreamConfigDelta 的客户端下一次被唤醒时就是拿这份清单去和自己已知的版本表做比对。
+	s.currentEntries = entries
 
-	// 5. 【广播推送】 (Broadcasting)
-	if len(s.clients) > 0 {
-		log.Printf("Broadcasting merged config version %s (Static Routes: %d, CRD Routes: %d, Static Clusters: %d, K8s Clusters: %d)",
-			version, len(staticCfg.Routes), len(k8sRoutes), len(staticCfg.Clusters), len(k8sClusters))
-
-		// 遍历所有已连接的数据面客户端
-		for _, ch := range s.clients { 
-			// 使用 select + default 进行非阻塞发送
-			// 如果某个客户端处理太慢导致 channel 满了，我们选择跳过它而不是阻塞整个控制平面
-			// (生产环境可能需要更复杂的重试或断开重连机制)
-			select {
-			case ch <- snapshot:
-				// 发送成功
-			default:
-				log.Println("Warning: client channel full, skipping update")
+	// 把这份刚刚真正推送出去的快照落盘，供下次进程重启后热启动（见 NewAgwServer）。
+	// 放到单独的 goroutine 里做，不占用 s.mu——磁盘 IO 的耗时不应该拖慢广播或新客户端注册。
+	if s.snapshotStore != nil {
+		store := s.snapshotStore
+		go func(snap *agwv1.ConfigSnapshot) {
+			if err := store.Save(snap); err != nil {
+				log.Printf("Warning: failed to persist snapshot: %v", err)
 			}
+		}(snapshot)
+	}
+
+	defer func() {
+		pushesTotal.Inc()
+		pushLatencySeconds.Observe(time.Since(start).Seconds())
+	}()
+
+	// 6. 【广播推送】 (Broadcasting)
+	if len(s.clients) > 0 {
+		log.Printf("Broadcasting merged config version %s (Listeners: %d, Routes: %d, Clusters: %d)",
+			version, len(snapshot.Listeners), len(routes), len(clusters))
+
+		// 遍历所有已连接的数据面客户端，把最新快照交给它专属的 pusher。push() 只是覆盖
+		// pending 并唤醒发送循环，不会阻塞在这里，真正的网络发送和慢消费者超时由
+		// clientPusher.run（在各自的 StreamConfig goroutine 里）负责。
+		for _, p := range s.clients {
+			p.push(snapshot)
 		}
 	}
+
+	// 同样唤醒所有 StreamConfigDelta 客户端。这里只发一个"有更新了"的信号，不在锁里直接算
+	// diff 或发送——diff 是按每个客户端已知的版本表算的，且可能涉及网络发送，不应该占着 s.mu。
+	s.broadcastDeltaNotify()
 }
 
-// registerClient 将一个新的数据平面连接注册到 clients 映射表中。
-// 返回生成的 clientID，以便后续注销。
-func (s *AgwServer) registerClient(ch chan *agwv1.ConfigSnapshot) int64 {
+// registerClient 将一个新的数据平面连接注册到 clients 映射表中，返回对应的 clientPusher
+// 和生成的 clientID，以便后续注销。
+func (s *AgwServer) registerClient(node *agwv1.Node) (*clientPusher, int64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
+
 	id := s.nextID
 	s.nextID++
-	s.clients[id] = ch // 把这个连接的专属信箱放入总列表
-	
-	// 如果此时已经有配置了，立刻发送一份当前的最新配置给新来的客户端
+	p := newClientPusher(id, node, s.pushDeadline)
+	s.clients[id] = p
+
+	// 如果此时已经有配置了，立刻把当前的最新配置设为这个新客户端的 pending
 	// 这样新启动的 Data Plane 不用等到下一次配置变更就能拿到初始配置
 	if s.current != nil {
-		go func() { ch <- s.current }()
+		p.push(s.current)
 	}
-	return id
+	return p, id
 }
 
 // unregisterClient 当连接断开时，从列表中移除该客户端。
@@ -225,30 +306,26 @@ func (s *AgwServer) unregisterClient(id int64) {
 func (s *AgwServer) StreamConfig(req *agwv1.Node, stream grpc.ServerStreamingServer[agwv1.ConfigSnapshot]) error {
 	log.Printf("New node connected: ID=%s Region=%s Version=%s", req.Id, req.Region, req.Version)
 
-	// 1. 创建一个专属的通道 (信箱)
-	// 这个通道用来接收来自 broadcastMerged 的配置快照
-	updateChan := make(chan *agwv1.ConfigSnapshot, 1)
-	
-	// 2. 注册：把这封信箱交给 AgwServer 管理
-	id := s.registerClient(updateChan)
-	// 3. 确保退出时注销 (defer)
+	// 1. 注册：创建这个连接专属的 pusher 并交给 AgwServer 管理
+	p, id := s.registerClient(req)
+	// 2. 确保退出时注销 (defer)
 	defer s.unregisterClient(id)
 
-	// 4. 进入死循环，守着这两个来源：
-	for {
-		select {
-		// A: 收到新配置了！(来自 updateChan)
-		// 这里的 snapshot 就是 broadcastMerged里 `case ch <- snapshot` 塞进来的那个
-		case snapshot := <-updateChan:
-			// 执行真正的网络发送
-			if err := stream.Send(snapshot); err != nil {
-				log.Printf("Error sending to %s: %v", req.Id, err)
-				return err // 发送失败（比如网络断了），函数返回，连接断开
-			}
-		
-		// B: 客户端主动断开了连接
-		case <-stream.Context().Done():
-			return nil
-		}
+	// 3. pusher.run 本身就是一个阻塞循环，守着 "有新快照要发" 和 "客户端断开" 两类事件，
+	// 并在客户端迟迟不读、送达超过 pushDeadline 时主动返回错误让这个流断开。
+	return p.run(stream)
+}
+
+// hashSnapshot 对合并后的整份快照取内容哈希，用作 VersionId 的一部分。
+// 合并逻辑改成从 controller 里现拉取之后，不再有一份天然带着哈希的 "静态配置版本号"
+// 可以复用（以前是 config.LoadConfig 从原始文件字节算出来的），所以在这里对 Marshal
+// 之后的快照重新算一次，沿用 config.GenerateVersion 同样的哈希长度，保持风格一致。
+func hashSnapshot(snapshot *agwv1.ConfigSnapshot) string {
+	// Deterministic: true，原因同 config.HashResource：Route.Plugin.Config 是
+	// map[string]string，不加这个选项同样内容的两次合并会算出不同的 VersionId。
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(snapshot)
+	if err != nil {
+		return "err"
 	}
+	return config.GenerateVersion(data)
 }
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 下面这组指标覆盖的是 runLoop 的去抖/合并层（见 grpc.go 的 runLoop、broadcastMerged）：
+// 一次 "kubectl apply -f dir/" 这样的突发变更会在短时间内触发一串更新信号，去抖窗口把它们
+// 合并成一次 broadcastMerged，这里记录合并发生了多少次、最终有多少次真正推送/被抑制，
+// 以及每次推送花了多久，方便运维判断去抖窗口是不是设得合适。
+var (
+	pushesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agw_control_plane_pushes_total",
+		Help: "Total number of merged config snapshots actually pushed to data-plane clients.",
+	})
+
+	pushesSuppressedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agw_control_plane_pushes_suppressed_total",
+		Help: "Total number of debounced broadcasts suppressed because no resource's per-type hash changed.",
+	})
+
+	pushLatencySeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "agw_control_plane_push_latency_seconds",
+		Help:    "Time spent merging sources and pushing the resulting snapshot to clients, per actual push.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	debounceCoalescedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "agw_control_plane_debounce_coalesced_total",
+		Help: "Total number of update signals absorbed by the debounce window instead of triggering their own broadcast.",
+	})
+)
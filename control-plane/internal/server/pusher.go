@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	"google.golang.org/grpc"
+)
+
+// clientPusher 取代了以前 broadcastMerged 里 "for ch := range s.clients { select { case ch <-
+// snapshot: default: log } }" 的做法：那种写法只要客户端的 1 缓冲 channel 被占满一次，
+// 后续的更新就会被无声丢弃，数据面会一直停留在旧配置上直到下一次全局变更才有机会追上。
+//
+// 这里换成每个 StreamConfig 连接专属一个 pusher：push() 只负责原地替换 pending（新快照
+// 覆盖旧快照，因为反正只关心"最新"的），真正的网络发送由 run() 里的循环串行完成，并且
+// 对每次 Send 套一个超时，超时就判定为慢消费者，断开连接让数据面重连。
+type clientPusher struct {
+	id     int64
+	nodeID string
+	region string
+	version string
+
+	deadline time.Duration
+
+	mu              sync.Mutex
+	pending         *agwv1.ConfigSnapshot
+	lastSentVersion string
+	lastError       string
+	lastPushAt      time.Time
+
+	wake chan struct{} // 容量 1，用来在 "有新的 pending" 时唤醒 run() 的发送循环
+}
+
+// newClientPusher 为一次新的 StreamConfig 连接创建 pusher。deadline 是单次 Send 允许的
+// 最长耗时，超过它就认为客户端是慢消费者（见 run()）。
+func newClientPusher(id int64, node *agwv1.Node, deadline time.Duration) *clientPusher {
+	p := &clientPusher{
+		id:       id,
+		deadline: deadline,
+		wake:     make(chan struct{}, 1),
+	}
+	if node != nil {
+		p.nodeID = node.Id
+		p.region = node.Region
+		p.version = node.Version
+	}
+	return p
+}
+
+// push 把 snapshot 设为这个客户端下一次要发送的内容，覆盖掉还没来得及发出去的旧快照
+// （conflate）。broadcastMerged 每次合并出新配置都会给所有已连接客户端调用一次。
+func (p *clientPusher) push(snapshot *agwv1.ConfigSnapshot) {
+	p.mu.Lock()
+	p.pending = snapshot
+	p.mu.Unlock()
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+		// 已经有一个待处理的唤醒信号了，run() 读 pending 的时候自然会拿到最新这份。
+	}
+}
+
+// run 是 pusher 的发送循环：每次被唤醒就把当前 pending 发出去，直到连接断开或者客户端
+// 迟迟不肯把数据读走（超过 deadline）。返回值会被 StreamConfig 直接作为 RPC 的返回值，
+// 非 nil 时 gRPC 框架会关闭这个流，数据面看到连接断开会自己重连。
+func (p *clientPusher) run(stream grpc.ServerStreamingServer[agwv1.ConfigSnapshot]) error {
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+
+		case <-p.wake:
+			p.mu.Lock()
+			snapshot := p.pending
+			p.pending = nil
+			p.mu.Unlock()
+			if snapshot == nil {
+				// 上一次唤醒已经把它发走了（wake 的 buffer 攒了两次信号但只有一份 pending）。
+				continue
+			}
+
+			// stream.Send 在 gRPC-Go 里本身没有暴露单次调用的超时参数，会一直等到写入底层
+			// 连接的 flow-control 窗口有空间为止——客户端不读，Send 就会一直卡住。起一个
+			// goroutine 执行 Send，用 select + time.After 给它套一个独立于 RPC 整体超时的
+			// 发送期限，到点还没完成就认定对方是慢消费者，断开连接。
+			done := make(chan error, 1)
+			go func() { done <- stream.Send(snapshot) }()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					p.setError(err.Error())
+					return err
+				}
+				p.mu.Lock()
+				p.lastSentVersion = snapshot.VersionId
+				p.lastPushAt = time.Now()
+				p.mu.Unlock()
+
+			case <-time.After(p.deadline):
+				err := fmt.Errorf("client %d (node=%s) did not drain push within %s, disconnecting", p.id, p.nodeID, p.deadline)
+				p.setError(err.Error())
+				log.Printf("Warning: %v", err)
+				return err
+			}
+		}
+	}
+}
+
+func (p *clientPusher) setError(msg string) {
+	p.mu.Lock()
+	p.lastError = msg
+	p.mu.Unlock()
+}
+
+// clientDebugInfo 是 /debug/clients 返回的单个客户端条目，字段故意都是值类型（而不是
+// 直接序列化 clientPusher），避免把内部的锁、channel 这些实现细节泄露到 HTTP 响应里。
+type clientDebugInfo struct {
+	ID              int64     `json:"id"`
+	NodeID          string    `json:"node_id"`
+	Region          string    `json:"region,omitempty"`
+	Version         string    `json:"version,omitempty"`
+	LastSentVersion string    `json:"last_sent_version,omitempty"`
+	LastPushAt      time.Time `json:"last_push_at,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+	HasPending      bool      `json:"has_pending"`
+}
+
+func (p *clientPusher) describe() clientDebugInfo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return clientDebugInfo{
+		ID:              p.id,
+		NodeID:          p.nodeID,
+		Region:          p.region,
+		Version:         p.version,
+		LastSentVersion: p.lastSentVersion,
+		LastPushAt:      p.lastPushAt,
+		LastError:       p.lastError,
+		HasPending:      p.pending != nil,
+	}
+}
@@ -0,0 +1,174 @@
+package aggregate
+
+import (
+	"log"
+	"sort"
+
+	"github.com/masallsome/masapigateway/control-plane/pkg/config"
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// ConfigController 把任意数量的 ConfigSource 聚合成控制面看到的一份全局配置。
+// AgwServer 只依赖这个聚合器（见 internal/server/grpc.go 的 NewAgwServer），不再直接持有
+// 具体的 *config.Watcher / *k8s.Registry，新增一个来源（Consul、远端 xDS 上游……）只需要
+// 实现 ConfigSource 并传给 NewConfigController，不需要改动 ConfigController 或 broadcastMerged。
+type ConfigController struct {
+	sources []ConfigSource
+	updates chan struct{}
+}
+
+// NewConfigController 创建一个聚合给定来源的 ConfigController，并为每个来源起一个
+// goroutine 把它的更新信号转发到聚合后的 Updates() 通道上。
+func NewConfigController(sources ...ConfigSource) *ConfigController {
+	c := &ConfigController{
+		sources: sources,
+		updates: make(chan struct{}, 1),
+	}
+	for _, src := range sources {
+		go c.pump(src)
+	}
+	return c
+}
+
+func (c *ConfigController) pump(src ConfigSource) {
+	for range src.Updates() {
+		select {
+		case c.updates <- struct{}{}:
+		default:
+			// 已经有一个待处理的信号了，聚合器自己不区分是哪个来源变了——反正下一次
+			// 重新合并时会把所有来源都再读一遍，多个来源挤在同一轮合并里也没问题。
+		}
+	}
+}
+
+// Updates 返回聚合后的信号通道：任意一个来源变化都会让它收到一个信号。
+func (c *ConfigController) Updates() <-chan struct{} {
+	return c.updates
+}
+
+// Ready 判断所有来源是不是都已经就绪：实现了 ReadyProvider 的来源要报告 Ready() == true，
+// 没实现它的来源（本地文件）视为总是就绪。broadcastMerged 在发布之前用它把关，避免在
+// K8s Informer 还没追上集群全量状态的窗口期发布一份只有部分 CRD 的快照。
+func (c *ConfigController) Ready() bool {
+	for _, src := range c.sources {
+		if rp, ok := src.(ReadyProvider); ok && !rp.Ready() {
+			return false
+		}
+	}
+	return true
+}
+
+// byPriorityDesc 按 Priority 从高到低排序来源的一份拷贝；调用方按这个顺序遍历来源，
+// 先遇到的名字就是赢家，这样 "保留最高优先级来源" 和 "排序" 可以用同一次遍历完成。
+func (c *ConfigController) byPriorityDesc() []ConfigSource {
+	sorted := append([]ConfigSource(nil), c.sources...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Priority() > sorted[j].Priority() })
+	return sorted
+}
+
+// mergeByName 按 sourcesOrdered 给定的顺序（高优先级来源在前）合并同名资源：每个来源贡献的
+// 列表各自按出现顺序扫描，名字第一次出现时记为赢家；同名资源再次出现（更低优先级来源）时
+// 只记一条冲突日志、丢弃这一份。最终结果按名字排序，保证同一份输入每次合并出的顺序都一样，
+// 推给数据面的配置不会因为 map 遍历顺序不同而产生无意义的 diff。
+func mergeByName[T any](kind string, sourcesOrdered []ConfigSource, list func(ConfigSource) []T, name func(item T, index int) string) []T {
+	winners := make(map[string]T)
+	winnerSource := make(map[string]string)
+	names := make([]string, 0)
+
+	for _, src := range sourcesOrdered {
+		for i, item := range list(src) {
+			n := name(item, i)
+			if ownerName, exists := winnerSource[n]; exists {
+				log.Printf("Warning: config conflict for %s %q: source %q overridden by higher-priority source %q", kind, n, src.Name(), ownerName)
+				continue
+			}
+			winners[n] = item
+			winnerSource[n] = src.Name()
+			names = append(names, n)
+		}
+	}
+
+	sort.Strings(names)
+	result := make([]T, 0, len(names))
+	for _, n := range names {
+		result = append(result, winners[n])
+	}
+	return result
+}
+
+func (c *ConfigController) ListListeners() []*agwv1.Listener {
+	return mergeByName("listener", c.byPriorityDesc(),
+		func(s ConfigSource) []*agwv1.Listener { return s.ListListeners() },
+		func(l *agwv1.Listener, _ int) string { return l.Name })
+}
+
+func (c *ConfigController) ListRoutes() []*agwv1.Route {
+	return mergeByName("route", c.byPriorityDesc(),
+		func(s ConfigSource) []*agwv1.Route { return s.ListRoutes() },
+		func(r *agwv1.Route, i int) string { return config.RouteName(r, i) })
+}
+
+func (c *ConfigController) ListClusters() []*agwv1.Cluster {
+	return mergeByName("cluster", c.byPriorityDesc(),
+		func(s ConfigSource) []*agwv1.Cluster { return s.ListClusters() },
+		func(cl *agwv1.Cluster, _ int) string { return cl.Name })
+}
+
+// GetSecret 按优先级顺序向每个来源查询 name，返回第一个（最高优先级）非空结果；
+// 如果不止一个来源持有同名 Secret，记一条冲突日志，和 mergeByName 的语义保持一致。
+func (c *ConfigController) GetSecret(name string) *Secret {
+	var winner *Secret
+	var winnerSourceName string
+	for _, src := range c.byPriorityDesc() {
+		secret := src.GetSecret(name)
+		if secret == nil {
+			continue
+		}
+		if winner == nil {
+			winner = secret
+			winnerSourceName = src.Name()
+			continue
+		}
+		log.Printf("Warning: config conflict for secret %q: source %q overridden by higher-priority source %q", name, src.Name(), winnerSourceName)
+	}
+	return winner
+}
+
+// ListResources 按名字合并所有实现了 ResourceProvider 的来源贡献的 Redis/Database 配置，
+// 和 ListListeners/ListRoutes/ListClusters 用的是同一套 "按优先级排序、同名冲突记日志" 规则
+// （见 mergeByName）。以前是整份 ExternalResources 对象 "优先级最高的来源赢者通吃"，现在
+// RedisResource/DatabaseResource CRD（见 pkg/k8s/resource_controller.go）也能贡献资源了，
+// 继续整体通吃会导致文件来源一旦定义了任何资源，K8s CRD 贡献的资源就被完全丢弃。
+func (c *ConfigController) ListResources() *agwv1.ExternalResources {
+	var providers []ConfigSource
+	for _, src := range c.byPriorityDesc() {
+		if _, ok := src.(ResourceProvider); ok {
+			providers = append(providers, src)
+		}
+	}
+
+	redis := mergeByName("redis resource", providers,
+		func(s ConfigSource) []*agwv1.RedisConfig {
+			resources := s.(ResourceProvider).ListResources()
+			if resources == nil {
+				return nil
+			}
+			return resources.Redis
+		},
+		func(r *agwv1.RedisConfig, _ int) string { return r.Name })
+
+	databases := mergeByName("database resource", providers,
+		func(s ConfigSource) []*agwv1.DatabaseConfig {
+			resources := s.(ResourceProvider).ListResources()
+			if resources == nil {
+				return nil
+			}
+			return resources.Databases
+		},
+		func(d *agwv1.DatabaseConfig, _ int) string { return d.Name })
+
+	if len(redis) == 0 && len(databases) == 0 {
+		return nil
+	}
+	return &agwv1.ExternalResources{Redis: redis, Databases: databases}
+}
@@ -0,0 +1,97 @@
+package aggregate
+
+import (
+	"sync"
+
+	"github.com/masallsome/masapigateway/control-plane/pkg/config"
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// PriorityFile 是本地静态文件来源的默认优先级。本地配置是运维手写的 ground truth，
+// 名字和动态发现的资源撞车时默认希望它赢，所以给一个比 PriorityK8s 更高的值。
+const PriorityFile = 100
+
+// fileSource 把 *config.Watcher 适配成 ConfigSource。Watcher 本身每次变化都吐出一份
+// *完整的* ConfigSnapshot（见 config/watcher.go），所以这里用一个 goroutine 把它们收下来，
+// 只保留最新的一份，ListListeners/ListRoutes/... 都是从这份缓存的快照里切片出来的。
+type fileSource struct {
+	priority int
+
+	mu     sync.RWMutex
+	latest *agwv1.ConfigSnapshot
+
+	updates chan struct{}
+}
+
+// NewFileSource 创建一个包装了本地文件 Watcher 的 ConfigSource。
+// w 为 nil 时（Watcher 初始化失败）这个来源永远不会产生任何资源，也不会发出更新信号，
+// 和历史行为一致：没有可用的静态配置时，控制面只靠其它来源（如 K8s）继续工作。
+func NewFileSource(w *config.Watcher, priority int) ConfigSource {
+	fs := &fileSource{priority: priority, updates: make(chan struct{}, 1)}
+	if w != nil {
+		go fs.pump(w)
+	}
+	return fs
+}
+
+func (fs *fileSource) pump(w *config.Watcher) {
+	for snapshot := range w.Updates() {
+		fs.mu.Lock()
+		fs.latest = snapshot
+		fs.mu.Unlock()
+
+		select {
+		case fs.updates <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (fs *fileSource) Name() string           { return "file" }
+func (fs *fileSource) Priority() int          { return fs.priority }
+func (fs *fileSource) Updates() <-chan struct{} { return fs.updates }
+
+func (fs *fileSource) snapshot() *agwv1.ConfigSnapshot {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.latest
+}
+
+func (fs *fileSource) ListListeners() []*agwv1.Listener {
+	snap := fs.snapshot()
+	if snap == nil {
+		return nil
+	}
+	return snap.Listeners
+}
+
+func (fs *fileSource) ListRoutes() []*agwv1.Route {
+	snap := fs.snapshot()
+	if snap == nil {
+		return nil
+	}
+	return snap.Routes
+}
+
+func (fs *fileSource) ListClusters() []*agwv1.Cluster {
+	snap := fs.snapshot()
+	if snap == nil {
+		return nil
+	}
+	return snap.Clusters
+}
+
+// GetSecret 对本地文件来源总是返回 nil：静态配置里 TlsConfig 只携带 SecretName 引用，
+// 真正的证书内容历来是由 K8s Secret 控制器提供的，文件来源从不持有证书字节。
+func (fs *fileSource) GetSecret(name string) *Secret {
+	return nil
+}
+
+// ListResources 实现 ResourceProvider：Redis/Database 这类外部资源目前只能来自本地静态配置。
+func (fs *fileSource) ListResources() *agwv1.ExternalResources {
+	snap := fs.snapshot()
+	if snap == nil {
+		return nil
+	}
+	return snap.Resources
+}
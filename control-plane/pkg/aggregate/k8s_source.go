@@ -0,0 +1,81 @@
+package aggregate
+
+import (
+	"github.com/masallsome/masapigateway/control-plane/pkg/k8s"
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// PriorityK8s 是 K8s 来源（GatewayRoute CRD、Ingress、Gateway API、EndpointSlice 聚合）的
+// 默认优先级，低于 PriorityFile：动态发现的资源和运维手写的静态配置撞名字时，静态配置赢。
+const PriorityK8s = 50
+
+// k8sSource 把 *k8s.Registry 适配成 ConfigSource。Registry 本身已经是按资源类型增量维护的
+// 聚合存储（见 pkg/k8s/registry.go），这里只是做一层接口转换，基本不持有任何额外状态——
+// 唯一的例外是 expectedSources/controllersActive，用来实现 ReadyProvider（见下方 Ready）。
+type k8sSource struct {
+	registry *k8s.Registry
+	priority int
+
+	// expectedSources 是 cmd/server/main.go 里实际拉起的那一组 K8s 控制器名字
+	// （"discovery"、"secret"……），Ready() 用它向 Registry.SourcesReady 问 "这些控制器是不是
+	// 都完成了首次 cache sync"。
+	expectedSources []string
+
+	// controllersActive 返回这个副本当前是不是真的在跑这些 K8s 控制器。选主模式下，
+	// 只有 leader 副本会调用 MarkSynced；一个从来没当过 leader（或者已经失去 leader 身份）
+	// 的副本永远等不到 SourcesReady 变 true。区分"还在同步"（临时，很快会变 true）和
+	// "压根没在跑"（这个副本当前的常态）很重要：前者应该继续阻塞 broadcastMerged，避免推送
+	// 半同步的残缺配置；后者不应该阻塞——Registry 本来就不会再有新数据，继续等没有意义，
+	// 还会连累 file 来源的更新也一起卡住。为 nil 时视为始终在跑（非选主模式的默认行为，
+	// 以及现有测试直接构造 k8sSource 时不需要关心这个字段）。
+	controllersActive func() bool
+}
+
+// NewK8sSource 创建一个包装了 K8s Registry 的 ConfigSource。expectedSources 是这个控制面
+// 实例实际会启动的 K8s 控制器名字，用于 Ready()；留空表示不做就绪判断，始终报告就绪。
+// controllersActive 为 nil 时视为这些控制器始终在跑（非选主部署的默认行为）；选主模式下
+// 调用方应该传入一个反映"当前是否持有 leader 身份"的函数，见 cmd/server/main.go。
+func NewK8sSource(registry *k8s.Registry, priority int, controllersActive func() bool, expectedSources ...string) ConfigSource {
+	return &k8sSource{registry: registry, priority: priority, controllersActive: controllersActive, expectedSources: expectedSources}
+}
+
+func (k *k8sSource) Name() string             { return "k8s" }
+func (k *k8sSource) Priority() int            { return k.priority }
+func (k *k8sSource) Updates() <-chan struct{} { return k.registry.Updates() }
+
+// ListListeners 对 K8s 来源总是返回空：目前没有任何 Controller 会从 CRD/Ingress/Gateway API
+// 翻译出 Listener，K8s 这一侧只贡献 Route 和 Cluster（以及通过 Secret 控制器贡献的证书）。
+func (k *k8sSource) ListListeners() []*agwv1.Listener { return nil }
+
+func (k *k8sSource) ListRoutes() []*agwv1.Route   { return k.registry.ListRoutes() }
+func (k *k8sSource) ListClusters() []*agwv1.Cluster { return k.registry.ListClusters() }
+
+func (k *k8sSource) GetSecret(name string) *Secret {
+	secret := k.registry.GetSecret(name)
+	if secret == nil {
+		return nil
+	}
+	return &Secret{Cert: secret.Cert, Key: secret.Key}
+}
+
+// ListResources 实现 ResourceProvider：Redis/Database 资源现在也可以来自 RedisResource/
+// DatabaseResource CRD（见 pkg/k8s/resource_controller.go），不再只有本地文件来源能贡献。
+func (k *k8sSource) ListResources() *agwv1.ExternalResources {
+	redis := k.registry.ListRedis()
+	databases := k.registry.ListDatabases()
+	if len(redis) == 0 && len(databases) == 0 {
+		return nil
+	}
+	return &agwv1.ExternalResources{Redis: redis, Databases: databases}
+}
+
+// Ready 实现 ReadyProvider：只有 expectedSources 列出的每个 K8s 控制器都完成了首次 informer
+// cache sync，这个来源才算就绪。但如果这个副本当前压根没有在跑这些控制器（选主模式下的
+// 非 leader 副本），就不再等——直接报告就绪（贡献空的 Route/Cluster 列表），这样 broadcastMerged
+// 不会被一个永远不会完成同步的来源卡住，file 来源的更新依然能正常广播给 followers。
+func (k *k8sSource) Ready() bool {
+	if k.controllersActive != nil && !k.controllersActive() {
+		return true
+	}
+	return k.registry.SourcesReady(k.expectedSources...)
+}
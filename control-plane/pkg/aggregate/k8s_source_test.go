@@ -0,0 +1,46 @@
+package aggregate
+
+import (
+	"testing"
+
+	"github.com/masallsome/masapigateway/control-plane/pkg/k8s"
+)
+
+// TestK8sSourceReadyWhenControllersNotActive 覆盖 chunk0-7 的回归场景：选主模式下，一个从来
+// 没当过 leader 的副本永远不会调用 Registry.MarkSynced，如果 Ready() 只看 SourcesReady，就会
+// 永远报告未就绪，连累 ConfigController.Ready() 卡住整个 broadcastMerged——哪怕 file 来源
+// 完全没问题。controllersActive 返回 false 时，Ready() 应该直接报告就绪。
+func TestK8sSourceReadyWhenControllersNotActive(t *testing.T) {
+	registry := k8s.NewRegistry("")
+	src := NewK8sSource(registry, PriorityK8s, func() bool { return false }, "discovery", "secret")
+
+	rp, ok := src.(ReadyProvider)
+	if !ok {
+		t.Fatal("k8sSource should implement ReadyProvider")
+	}
+	if !rp.Ready() {
+		t.Fatal("expected Ready() to report true when controllersActive reports false, got false")
+	}
+}
+
+// TestK8sSourceReadyWaitsForSyncWhenControllersActive 覆盖正常的选主/非选主启动路径：控制器
+// 确实在跑的时候，Ready() 仍然要按 SourcesReady 等首次 cache sync 完成，不能被误判为永远就绪。
+func TestK8sSourceReadyWaitsForSyncWhenControllersActive(t *testing.T) {
+	registry := k8s.NewRegistry("")
+	src := NewK8sSource(registry, PriorityK8s, func() bool { return true }, "discovery", "secret")
+	rp := src.(ReadyProvider)
+
+	if rp.Ready() {
+		t.Fatal("expected Ready() to be false before any source has synced")
+	}
+
+	registry.MarkSynced("discovery")
+	if rp.Ready() {
+		t.Fatal("expected Ready() to still be false with one of two expected sources synced")
+	}
+
+	registry.MarkSynced("secret")
+	if !rp.Ready() {
+		t.Fatal("expected Ready() to be true once all expected sources have synced")
+	}
+}
@@ -0,0 +1,53 @@
+// Package aggregate 把多个来源（本地静态文件、K8s CRD、未来的 Consul/远端 xDS 上游等）
+// 贡献的配置聚合成控制面对外推送的一份全局配置，角色上对应 Istio Pilot 里聚合多个
+// Config Controller 的那一层：新增一个来源只需要实现 ConfigSource，不需要改动
+// ConfigController 本身，更不需要改动 AgwServer.broadcastMerged。
+package aggregate
+
+import (
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// Secret 封装了 TLS 证书和私钥的字节内容，供 ConfigSource.GetSecret 返回。
+// 之所以在这里单独定义而不是直接复用 k8s.TlsSecret，是因为 ConfigSource 要保持对
+// 任意来源中立——它不应该依赖某一个具体来源包（k8s、consul、...）的内部类型。
+type Secret struct {
+	Cert []byte
+	Key  []byte
+}
+
+// ConfigSource 是一个可以贡献 Listener/Route/Cluster/Secret 的配置来源。
+// file、k8s 两个来源已经实现了它（见 file_source.go、k8s_source.go）；Consul 或者远端
+// MCP/xDS 上游只需要提供同样的一套方法就能接入 ConfigController，不需要改动聚合逻辑。
+type ConfigSource interface {
+	// Name 返回这个来源的可读标识，用于日志和冲突诊断（如 "file"、"k8s"）。
+	Name() string
+
+	// Priority 数值越大优先级越高。多个来源贡献了同名资源时，ConfigController 保留
+	// 优先级最高的那一份，并把被盖掉的来源记录到冲突日志里。
+	Priority() int
+
+	// Updates 返回一个信号通道：这个来源底层的数据发生变化时会往里写一个信号（非阻塞，
+	// 满了就丢弃），语义上和 config.Watcher.Updates()/k8s.Registry.Updates() 的 "脏位" 约定一致。
+	Updates() <-chan struct{}
+
+	ListListeners() []*agwv1.Listener
+	ListRoutes() []*agwv1.Route
+	ListClusters() []*agwv1.Cluster
+	GetSecret(name string) *Secret
+}
+
+// ResourceProvider 是一个可选接口：能够贡献 Redis/Database 等外部资源定义的来源可以
+// 额外实现它。没有把它并入 ConfigSource，是因为目前只有本地文件来源会用到，强迫
+// Consul/远端 xDS 这类来源也实现一个大概率用不上的方法没有必要。
+type ResourceProvider interface {
+	ListResources() *agwv1.ExternalResources
+}
+
+// ReadyProvider 是另一个可选接口：来源需要一段启动后的预热期（比如 K8s 来源要等
+// Informer cache 完成首次全量同步）才会报告自己的数据是完整的，就应该实现它。
+// 没有实现它的来源（比如本地文件，Watcher 加载失败就已经在日志里报警过了）
+// 被 ConfigController.Ready 当作一直就绪处理。
+type ReadyProvider interface {
+	Ready() bool
+}
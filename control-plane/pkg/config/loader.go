@@ -7,6 +7,7 @@ import (
 	"os"
 
 	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	"google.golang.org/protobuf/proto"
 	"gopkg.in/yaml.v3"
 )
 
@@ -111,3 +112,34 @@ func GenerateVersion(data []byte) string {
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:])[:8]
 }
+
+// HashResource 为单个 proto 消息计算内容哈希，产出跟 GenerateVersion 同样长度的版本号。
+// delta xDS 推送需要给 Listener/Route/Cluster/Secret 这些资源分别打版本号，不能再像
+// GenerateVersion 那样只对整份配置文件的原始字节算一个全局版本，否则任何一处改动都会
+// 导致所有资源被判定为"已变化"，delta 就失去了意义。
+func HashResource(msg proto.Message) string {
+	// Deterministic: true 是必须的——Plugin.Config 是 map[string]string（见 ToProto），
+	// 普通 proto.Marshal 不保证 map 遍历顺序，同样内容的两次 Marshal 会产出不同字节，
+	// 导致 delta.go 的版本号比对和 chunk1-3 的 no-op 推送抑制都不稳定。
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		// 正常情况下不会发生：这里的消息都是我们自己构造的，不存在未知字段或循环引用。
+		// 保底返回一个非空但不稳定的值，宁可让 delta 把它当成"又变了"重新推送一次，
+		// 也不要因为返回空字符串而被误判为"没变化"从而漏发。
+		return fmt.Sprintf("err-%p", msg)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])[:8]
+}
+
+// RouteName 为没有显式名字的 agwv1.Route 合成一个足够稳定的标识。agwv1.Route 本身没有
+// Name 字段（DSL 从不要求给路由命名），但 delta 版本号比对（见 internal/server/delta.go）
+// 和跨来源合并的冲突检测（见 pkg/aggregate）都需要用名字去认出 "同一个" 资源，所以统一
+// 在这里实现一次，避免两处各写一份、慢慢长出不一致的合成规则。
+// index 只在 PathPrefix 和 ClusterId 都为空时作为兜底，保证不会有两条路由合成出同一个名字。
+func RouteName(r *agwv1.Route, index int) string {
+	if r.PathPrefix == "" && r.ClusterId == "" {
+		return fmt.Sprintf("route-%d", index)
+	}
+	return fmt.Sprintf("%s->%s", r.PathPrefix, r.ClusterId)
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// Plugin.Config 是 map[string]string，Go 的 map 遍历顺序在同一个进程里也是随机的。
+// 不加 Deterministic: true 的 proto.Marshal 会让同样内容的两次 HashResource 调用偶尔
+// 算出不同的字节、从而算出不同的哈希，这正是 delta 版本号比对依赖的"内容不变 -> 哈希不变"
+// 被打破的那种 bug：这个测试多跑几次 Marshal，断言哈希始终一致。
+func TestHashResourceIsDeterministicWithMapFields(t *testing.T) {
+	route := &agwv1.Route{
+		PathPrefix: "/api",
+		ClusterId:  "backend",
+		Plugins: []*agwv1.Plugin{
+			{
+				Name: "headers",
+				Config: map[string]string{
+					"a": "1",
+					"b": "2",
+					"c": "3",
+					"d": "4",
+					"e": "5",
+				},
+			},
+		},
+	}
+
+	want := HashResource(route)
+	for i := 0; i < 20; i++ {
+		if got := HashResource(route); got != want {
+			t.Fatalf("HashResource is not deterministic: run %d got %q, want %q", i, got, want)
+		}
+	}
+}
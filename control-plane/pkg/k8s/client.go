@@ -4,23 +4,78 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
 	"k8s.io/client-go/util/homedir"
+
+	// 注册 GCP/Azure/OIDC 等云厂商的 exec 认证插件。kubeconfig 里的 `exec`/`auth-provider`
+	// 字段要用到它们时（GKE `gke-gcloud-auth-plugin`、AKS、OIDC token 刷新等），必须有这个
+	// 空白导入触发各插件的 init() 注册，否则 client-go 会在鉴权时报 "no Auth Provider found"。
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
+// AuthConfig 描述连接 API Server 时使用的鉴权方式和限流参数。
+// 留空 (nil) 时完全等价于旧版 NewClient()/NewDynamicClient() 的行为：只走
+// getRestConfig 原有的三段式策略（KUBECONFIG -> ~/.kube/config -> in-cluster），
+// 不强加任何新的默认值，保证现有调用方不受影响。
+type AuthConfig struct {
+	// BearerTokenFile 指向一个 token 文件，client-go 会周期性重新读取它而不是只读一次。
+	// 典型用途是挂载进 Pod 的 projected ServiceAccount token 或会被外部进程轮换的 OIDC token。
+	// 优先级高于 kubeconfig/in-cluster config 自带的认证信息。
+	BearerTokenFile string
+
+	// WrapTransport 让调用方在最终的 http.RoundTripper 外面再包一层，
+	// 常见场景是需要定期轮换客户端证书的 mTLS 接入。
+	WrapTransport transport.WrapperFunc
+
+	// QPS/Burst 覆盖 client-go 默认的 5 QPS / 10 Burst。大规模集群下 Service/EndpointSlice
+	// 的 List 调用量很容易触达默认值，导致控制面自己把自己限流、拖慢首次 cache sync。
+	QPS   float32
+	Burst int
+
+	// Timeout 是单次请求的超时时间，0 表示沿用 rest.Config 的默认值（不超时）。
+	Timeout time.Duration
+}
+
+// applyTo 把 AuthConfig 里设置过的字段叠加到一个已经解析好的 rest.Config 上；
+// 零值字段保持 rest.Config 原有的值不变。
+func (a *AuthConfig) applyTo(config *rest.Config) {
+	if a == nil {
+		return
+	}
+	if a.BearerTokenFile != "" {
+		config.BearerTokenFile = a.BearerTokenFile
+	}
+	if a.WrapTransport != nil {
+		config.WrapTransport = a.WrapTransport
+	}
+	if a.QPS > 0 {
+		config.QPS = a.QPS
+	}
+	if a.Burst > 0 {
+		config.Burst = a.Burst
+	}
+	if a.Timeout > 0 {
+		config.Timeout = a.Timeout
+	}
+}
+
 // NewClient 创建并返回一个新的 Kubernetes Clientset。
 // 它负责初始化与 Kubernetes API Server 交互的标准客户端。
 // 配置加载策略遵循 getRestConfig 中的定义：环境变量 -> 本地配置 -> 集群内部配置。
-func NewClient() (*kubernetes.Clientset, *rest.Config, error) {
+// auth 为 nil 时使用 client-go 的默认鉴权/限流行为；非 nil 时按 AuthConfig.applyTo 叠加。
+func NewClient(auth *AuthConfig) (*kubernetes.Clientset, *rest.Config, error) {
 	// 获取 Kubernetes REST 配置
 	config, err := getRestConfig()
 	if err != nil {
 		return nil, nil, err
 	}
+	auth.applyTo(config)
 
 	// 使用配置创建 Clientset
 	clientset, err := kubernetes.NewForConfig(config)
@@ -34,12 +89,14 @@ func NewClient() (*kubernetes.Clientset, *rest.Config, error) {
 // CRD的
 // NewDynamicClient 创建并返回一个新的 Dynamic Client。
 // Dynamic Client 用于处理未知的或自定义的 Kubernetes 资源（如 CRD）。
-func NewDynamicClient() (*dynamic.DynamicClient, error) {
+// auth 为 nil 时使用 client-go 的默认鉴权/限流行为；非 nil 时按 AuthConfig.applyTo 叠加。
+func NewDynamicClient(auth *AuthConfig) (*dynamic.DynamicClient, error) {
 	// 获取 Kubernetes REST 配置
 	config, err := getRestConfig()
 	if err != nil {
 		return nil, err
 	}
+	auth.applyTo(config)
 
 	// 使用配置创建 Dynamic Client
 	client, err := dynamic.NewForConfig(config)
@@ -55,6 +112,11 @@ func NewDynamicClient() (*dynamic.DynamicClient, error) {
 // 1. KUBECONFIG 环境变量（通常用于开发环境指定特定配置）
 // 2. ~/.kube/config 文件（本地开发环境的默认路径）
 // 3. In-Cluster Config（生产环境，Pod 内部自动加载 ServiceAccount Token）
+//
+// 云厂商的 exec 凭据插件（EKS aws-iam-authenticator、GKE gke-gcloud-auth-plugin、AKS 等）
+// 和 OIDC token 刷新都是通过 kubeconfig 里的 `exec`/`auth-provider` 字段声明的，
+// clientcmd.BuildConfigFromFlags 会原样解析出对应的 rest.Config.ExecProvider/AuthProvider，
+// 不需要在这里额外处理；本文件顶部的空白导入负责把这些插件注册进 client-go。
 func getRestConfig() (*rest.Config, error) {
 	// 1. 尝试从 KUBECONFIG 环境变量加载配置 (开发模式常用)
 	// 如果设置了 KUBECONFIG 环境变量，则直接使用该路径下的配置文件
@@ -4,193 +4,360 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
-	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 )
 
+// resyncPeriod 是 Informer 的强制全量重新同步周期，也是 workqueue 版本里唯一剩下的
+// "周期性全量扫一遍" 的地方：正常情况下我们只 reconcile 被事件触碰到的对象，
+// resync 只是兜底，防止某次事件丢失导致 Registry 和集群实际状态永久不一致。
+const resyncPeriod = 30 * time.Second
+
+// gatewayRouteGVR 是 GatewayRoute CRD 的 Group/Version/Resource 坐标。
+var gatewayRouteGVR = schema.GroupVersionResource{
+	Group:    "agw.masallsome.io",
+	Version:  "v1",
+	Resource: "gatewayroutes",
+}
+
+// numWorkers 是每个资源类型启动的 reconcile worker goroutine 数量。
+const numWorkers = 2
+
 // Controller 负责监听 Kubernetes 资源的变化并将最新的状态同步到 Registry 中。
 // 它是连接 Kubernetes 集群状态和网关内部配置状态的桥梁。
+//
+// 采用标准的 client-go workqueue 模式：Informer 的事件回调只负责把对象的 key
+// ("namespace/name") 塞进对应资源类型的 workqueue，真正的处理逻辑（reconcile）
+// 由固定数量的 worker goroutine 从队列里取 key、通过 Lister 查询最新对象、再写入 Registry。
+// 好处：
+//  1. 事件处理和业务逻辑解耦，重活不会堵塞 Informer 的事件分发。
+//  2. reconcile 失败（例如解析错误）可以通过 RateLimitingInterface 自动退避重试，而不是直接丢弃。
+//  3. 单个对象的变更只需要处理这一个对象，不再是 "来了一个事件就把所有对象全部重算一遍"。
 type Controller struct {
-	client      *kubernetes.Clientset                // 标准 K8s 客户端，用于访问 Core 资源（如 Service）
-	dynClient   dynamic.Interface                    // 动态 K8s 客户端，用于访问 CRD 资源（如 GatewayRoute）
-	factory     informers.SharedInformerFactory      // 标准资源的 Informer 工厂，统一管理 Service/EndpointSlice 的监听
-	dynFactory  dynamicinformer.DynamicSharedInformerFactory // 动态资源的 Informer 工厂，统一管理 CRD 的监听
-	serviceInf  cache.SharedIndexInformer            // Service 资源的监听器
-	sliceInf    cache.SharedIndexInformer            // EndpointSlice 资源的监听器（用于获取 Pod IP）
-	registry    *Registry                            // 内部服务注册中心，Controller 将 K8s 的变化转换后更新到这里
-	routeLister cache.GenericLister                  // GatewayRoute 的 Lister，用于从本地缓存中快速查询路由规则
-	routeSynced cache.InformerSynced                 // 一个函数，用于检查 GatewayRoute 的缓存是否已经同步完成
+	// clusterID 标识这个 Controller 实例所监听的成员集群，写入 Registry 的聚合键
+	// ("clusterID/namespace/serviceName")，使多个集群的同名 Service 不会互相覆盖。
+	// 单集群部署（未启用 MultiClusterConfig）时为空字符串，serviceKey 会回退到 defaultClusterID。
+	clusterID string
+
+	client    *kubernetes.Clientset                        // 标准 K8s 客户端，用于访问 Core 资源（如 Service）
+	dynClient dynamic.Interface                             // 动态 K8s 客户端，用于访问 CRD 资源（如 GatewayRoute）
+	factory   informers.SharedInformerFactory               // 标准资源的 Informer 工厂，统一管理 Service/EndpointSlice 的监听
+	dynFactory dynamicinformer.DynamicSharedInformerFactory // 动态资源的 Informer 工厂，统一管理 CRD 的监听
+
+	serviceInf    cache.SharedIndexInformer
+	serviceLister corelisters.ServiceLister
+
+	sliceInf    cache.SharedIndexInformer
+	sliceLister discoverylisters.EndpointSliceLister
+
+	routeInf    cache.SharedIndexInformer
+	routeLister cache.GenericLister // GatewayRoute 是 CRD，只能用 GenericLister
+
+	registry *Registry // 内部服务注册中心，Controller 将 K8s 的变化转换后更新到这里
+
+	serviceQueue workqueue.RateLimitingInterface
+	sliceQueue   workqueue.RateLimitingInterface
+	routeQueue   workqueue.RateLimitingInterface
+
+	// sliceServiceIndex 记录每个 EndpointSlice key ("namespace/sliceName") 最近一次
+	// reconcile 到的所属 Service key。EndpointSlice 被删除后 Lister 里就再也查不到它了，
+	// 但 Registry.DeleteEndpointSlice 需要知道它属于哪个 Service 才能精确地只撤销这一份贡献，
+	// 所以在对象还能拿到的时候把这层映射记下来，删除事件到达时查表即可。
+	sliceServiceIndexMu sync.Mutex
+	sliceServiceIndex   map[string]string
 }
 
 // NewController 初始化一个新的控制器实例。
 // 它负责装配所有的 "情报系统"：
 // 1. 创建共享 Informer 工厂 (Factory)
-// 2. 从工厂中获取特定资源的 Informer (Service, EndpointSlice)
+// 2. 从工厂中获取特定资源的 Informer 和 Lister (Service, EndpointSlice)
 // 3. 配置动态客户端以监听自定义资源 (GatewayRoute)
-// 4. 注册事件回调函数 (Add/Update/Delete)
-func NewController(client *kubernetes.Clientset, dynClient dynamic.Interface, registry *Registry) *Controller {
-	// 创建标准资源的 SharedInformerFactory。
-	// 30*time.Second 是 "Resync Period" (重新同步周期)。
-	// 即使没有变更，Informer 也会每隔 30秒 强制触发一次 Update 事件，确保本地缓存和 Registry 不会因为漏掉事件而永久不一致。
-	factory := informers.NewSharedInformerFactory(client, 30*time.Second) 
-	
-	// 从工厂获取 "外勤特工" (Informer)
-	// serviceInf: 监听 Core/V1 下的 Service 资源
-	serviceInf := factory.Core().V1().Services().Informer()
-	// sliceInf: 监听 Discovery/V1 下的 EndpointSlice 资源 (比旧的 Endpoints 性能更好)
-	sliceInf := factory.Discovery().V1().EndpointSlices().Informer()
-
-	// 配置动态 Informer 以监听 GatewayRoute CRD
-	// 因为是自定义资源，必须指定 GVR (Group, Version, Resource) 坐标
-	gvr := schema.GroupVersionResource{
-		Group:    "agw.masallsome.io",
-		Version:  "v1",
-		Resource: "gatewayroutes",
-	}
-	// 创建动态资源的 SharedInformerFactory
-	dynFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynClient, 30*time.Second)
-	// 获取 GatewayRoute 的 Informer 和 Lister
-	routeInf := dynFactory.ForResource(gvr).Informer()
-	routeLister := dynFactory.ForResource(gvr).Lister()
-
-	// 组装 Controller 结构体
+// 4. 为每种资源创建独立的 workqueue，并注册只做入队操作的事件回调
+//
+// clusterID 标识这个 Controller 实例归属的成员集群，单集群部署传空字符串即可。
+func NewController(clusterID string, client *kubernetes.Clientset, dynClient dynamic.Interface, registry *Registry) *Controller {
+	factory := informers.NewSharedInformerFactory(client, resyncPeriod)
+
+	serviceInformer := factory.Core().V1().Services()
+	sliceInformer := factory.Discovery().V1().EndpointSlices()
+
+	dynFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynClient, resyncPeriod)
+	routeGenericInformer := dynFactory.ForResource(gatewayRouteGVR)
+
 	c := &Controller{
-		client:      client,
-		dynClient:   dynClient,
-		factory:     factory,
-		dynFactory:  dynFactory,
-		serviceInf:  serviceInf,
-		sliceInf:    sliceInf,
-		registry:    registry,
-		routeLister: routeLister,
-		routeSynced: routeInf.HasSynced,
-	}
-
-	// 注册 Service 变更的事件回调
-	// 当 K8s 中 Service 发生增删改时，触发 c.onServiceXXX 方法
-	serviceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onServiceAdd,
-		UpdateFunc: c.onServiceUpdate,
-		DeleteFunc: c.onServiceDelete,
+		clusterID:  clusterID,
+		client:     client,
+		dynClient:  dynClient,
+		factory:    factory,
+		dynFactory: dynFactory,
+
+		serviceInf:    serviceInformer.Informer(),
+		serviceLister: serviceInformer.Lister(),
+
+		sliceInf:    sliceInformer.Informer(),
+		sliceLister: sliceInformer.Lister(),
+
+		routeInf:    routeGenericInformer.Informer(),
+		routeLister: routeGenericInformer.Lister(),
+
+		registry: registry,
+
+		serviceQueue: workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		sliceQueue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		routeQueue:   workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+
+		sliceServiceIndex: make(map[string]string),
+	}
+
+	c.serviceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue(c.serviceQueue, obj) },
+		UpdateFunc: func(old, new interface{}) { enqueue(c.serviceQueue, new) },
+		DeleteFunc: func(obj interface{}) { enqueue(c.serviceQueue, obj) },
 	})
 
-	// 注册 EndpointSlice 变更的事件回调
-	// 这是感知 Pod IP 变化的核心机制
-	sliceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    c.onSliceAdd,
-		UpdateFunc: c.onSliceUpdate,
-		DeleteFunc: c.onSliceDelete,
+	c.sliceInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue(c.sliceQueue, obj) },
+		UpdateFunc: func(old, new interface{}) { enqueue(c.sliceQueue, new) },
+		DeleteFunc: func(obj interface{}) { enqueue(c.sliceQueue, obj) },
 	})
 
-	// 注册 GatewayRoute (CRD) 变更的事件回调
-	// 任何路由规则的变化都会触发 rebuildRoutes，全量重新计算路由表
-	routeInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc:    func(obj interface{}) { c.rebuildRoutes() },
-		UpdateFunc: func(old, new interface{}) { c.rebuildRoutes() },
-		DeleteFunc: func(obj interface{}) { c.rebuildRoutes() },
+	c.routeInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue(c.routeQueue, obj) },
+		UpdateFunc: func(old, new interface{}) { enqueue(c.routeQueue, new) },
+		DeleteFunc: func(obj interface{}) { enqueue(c.routeQueue, obj) },
 	})
-	
+
 	return c
 }
 
-// Run 启动控制器的主要循环。
+// enqueue 把对象的 "namespace/name" key 塞进队列。
+// cache.DeletionHandlingMetaNamespaceKeyFunc 会自动处理 cache.DeletedFinalStateUnknown
+// 墓碑对象（Informer 在还没来得及处理 Delete 事件前，因为 watch 连接断开重建而补发的一种
+// "我们已经知道它没了，但手头只有它最后的快照" 的包装），从中取出 key。
+func enqueue(queue workqueue.RateLimitingInterface, obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		log.Printf("Failed to compute key for object: %v", err)
+		return
+	}
+	queue.Add(key)
+}
+
+// Run 启动控制器的主要循环：启动 Informer 工厂、等待缓存同步，然后拉起每种资源的 worker。
 // 该方法会一直阻塞，直到 ctx 被取消（程序退出）。
 func (c *Controller) Run(ctx context.Context) {
 	log.Println("Starting K8s Discovery Controller...")
-	
-	// 启动 Informer 工厂（在后台开始缓存数据）
-	// 这里分别启动了标准资源 (Service, EndpointSlice) 和 动态资源 (GatewayRoute) 的监听工厂
+
+	defer c.serviceQueue.ShutDown()
+	defer c.sliceQueue.ShutDown()
+	defer c.routeQueue.ShutDown()
+
 	go c.factory.Start(ctx.Done())
 	go c.dynFactory.Start(ctx.Done())
-	
-	// 等待所有的 Informer 缓存同步完成
-	// 这是为了确保在处理事件之前，我们已经拥有了集群的完整初始状态
-	// 如果超时（通常说明 API Server 连不上），则退出
-	if !cache.WaitForCacheSync(ctx.Done(), c.serviceInf.HasSynced, c.sliceInf.HasSynced, c.routeSynced) {
+
+	// 等待所有的 Informer 缓存同步完成，确保开始处理事件之前已经拥有了集群的完整初始状态。
+	if !cache.WaitForCacheSync(ctx.Done(), c.serviceInf.HasSynced, c.sliceInf.HasSynced, c.routeInf.HasSynced) {
 		log.Println("Timed out waiting for caches to sync")
 		return
 	}
-	
-	// 定义 GatewayRoute 的 GVR (Group, Version, Resource) 坐标
-	// 因为它是 CRD，Go 客户端代码中没有它的强类型定义，所以需要使用 Dynamic Client + GVR 来访问
-	gvr := schema.GroupVersionResource{
-		Group:    "agw.masallsome.io",
-		Version:  "v1",
-		Resource: "gatewayroutes",
-	}
-
-	// 创建一个专门用于 GatewayRoute 的 Dynamic Informer
-	// dynamicinformer 允许我们像监听原生资源一样监听 CRD
-	dynInformer := dynamicinformer.NewDynamicSharedInformerFactory(c.dynClient, 0)
-	informer := dynInformer.ForResource(gvr).Informer()
-
-	// 注册事件处理函数
-	// 无论是新增、更新还是删除 GatewayRoute，我们都触发 rebuildRoutes()
-	// rebuildRoutes 会全量重新计算路由表并推送到 Registry
-	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			c.rebuildRoutes()
-		},
-		UpdateFunc: func(old, new interface{}) {
-			c.rebuildRoutes()
-		},
-		DeleteFunc: func(obj interface{}) {
-			c.rebuildRoutes()
-		},
-	})
-	
-	log.Println("Starting GatewayRoute Watcher...")
-	// 启动并等待这个特定的 CRD informer 同步
-	dynInformer.Start(ctx.Done())
-	cache.WaitForCacheSync(ctx.Done(), informer.HasSynced)
-	
-	// 此时所有 Watcher 都在后台运行，主线程可以通过 <-ctx.Done() 阻塞（在 main.go 调用处体现）
+	log.Println("K8s Discovery Controller caches synced, starting workers")
+	c.registry.MarkSynced("discovery")
+
+	for i := 0; i < numWorkers; i++ {
+		go wait.Until(func() { c.runWorker(ctx, c.serviceQueue, c.reconcileService) }, time.Second, ctx.Done())
+		go wait.Until(func() { c.runWorker(ctx, c.sliceQueue, c.reconcileSlice) }, time.Second, ctx.Done())
+		go wait.Until(func() { c.runWorker(ctx, c.routeQueue, c.reconcileRoute) }, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+	log.Println("Stopping K8s Discovery Controller")
 }
 
-// rebuildRoutes 遍历所有缓存中的 GatewayRoute CRD，解析并重新构建路由表。
-// 这是一个 "世界重构 (World Rebuild)" 的过程：只要有任何一个路由发生变化，
-// 我们就重新扫描所有路由，生成最新的全量路由列表。
-func (c *Controller) rebuildRoutes() {
-	// 1. 从本地缓存 (Lister) 中获取所有的 GatewayRoute 对象
-	objs, err := c.routeLister.List(labels.Everything())
+// runWorker 是 worker goroutine 的主循环：不断从队列取 key 交给 reconcile 处理，
+// 直到队列被 ShutDown。workqueue 本身保证同一个 key 不会被两个 worker 同时处理。
+func (c *Controller) runWorker(ctx context.Context, queue workqueue.RateLimitingInterface, reconcile func(ctx context.Context, key string) error) {
+	for {
+		key, shutdown := queue.Get()
+		if shutdown {
+			return
+		}
+		func() {
+			defer queue.Done(key)
+
+			if err := reconcile(ctx, key.(string)); err != nil {
+				// reconcile 失败（例如解析错误、API Server 瞬时不可用）：按指数退避重新入队重试。
+				log.Printf("Error reconciling %q, requeuing: %v", key, err)
+				queue.AddRateLimited(key)
+				return
+			}
+			// 成功：清除这个 key 的失败计数，下次失败会从最小退避时间重新算起。
+			queue.Forget(key)
+		}()
+	}
+}
+
+// reconcileService 处理单个 Service 对象。
+// MVP 阶段我们主要依赖 EndpointSlice 做服务发现，Service 本身删除时需要把它名下的
+// 所有聚合数据从 Registry 清掉（EndpointSlice 理论上也会被 GC，但不必等那些事件逐个到达）。
+func (c *Controller) reconcileService(_ context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
-		log.Printf("Error listing GatewayRoutes: %v", err)
-		return
+		return err
 	}
 
-	var routes []*agwv1.Route
-	for _, obj := range objs {
-		// 2. 类型断言：因为是 Dynamic Client，拿到的对象是 *unstructured.Unstructured
-		// 它本质上是一个 map[string]interface{}，用来存储未知的 CRD 数据结构
-		u, ok := obj.(*unstructured.Unstructured)
-		if !ok {
-			continue
+	_, err = c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.registry.DeleteService(c.clusterID, namespace, name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// For basic discovery, we rely on EndpointSlices. Services are useful if we need
+	// ClusterIP/NodePort info or explicit port-name mapping; nothing to do here yet.
+	return nil
+}
+
+// reconcileSlice 处理单个 EndpointSlice 对象的新增/更新/删除。
+func (c *Controller) reconcileSlice(_ context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	slice, err := c.sliceLister.EndpointSlices(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		// 对象已经从集群中删除，Lister 里查不到了：查本地索引找出它最后归属的 Service。
+		c.sliceServiceIndexMu.Lock()
+		svcKey, known := c.sliceServiceIndex[key]
+		delete(c.sliceServiceIndex, key)
+		c.sliceServiceIndexMu.Unlock()
+
+		if known {
+			svcNamespace, svcName, splitErr := cache.SplitMetaNamespaceKey(svcKey)
+			if splitErr == nil {
+				c.registry.DeleteEndpointSlice(c.clusterID, svcNamespace, svcName, name)
+			}
 		}
-		
-		// 3. 解析单个 CRD 对象
-		route := c.parseRoute(u)
-		if route != nil {
-			routes = append(routes, route)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	svcName := slice.Labels[discoveryv1.LabelServiceName]
+	if svcName == "" {
+		return nil
+	}
+
+	c.sliceServiceIndexMu.Lock()
+	c.sliceServiceIndex[key] = serviceKey(c.clusterID, namespace, svcName)
+	c.sliceServiceIndexMu.Unlock()
+
+	c.registry.UpdateEndpointSlice(c.clusterID, slice)
+	return nil
+}
+
+// reconcileRoute 处理单个 GatewayRoute CRD 对象的新增/更新/删除，并把 reconcile 的结果
+// (Accepted/ResolvedRefs/Programmed 条件) 写回到对象的 status 子资源，这样
+// `kubectl describe gatewayroute` 就能看到这条路由到底有没有被控制面接受、为什么没被接受。
+func (c *Controller) reconcileRoute(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	// Registry.routes 是一个跨来源共享的 key 空间（GatewayRoute CRD、Ingress、Gateway API
+	// HTTPRoute 都往里面写），所以这里要加上来源前缀，否则不同来源但同名的对象会互相覆盖。
+	registryKey := "gatewayroute/" + key
+
+	obj, err := c.routeLister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.registry.DeleteRoute(registryKey)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for GatewayRoute %s: %T", key, obj)
+	}
+
+	route, status := c.parseRoute(u)
+	if route != nil {
+		c.registry.UpsertRoute(registryKey, route)
+	} else {
+		c.registry.DeleteRoute(registryKey)
+	}
+
+	if statusErr := c.writeRouteStatus(ctx, u, status); statusErr != nil {
+		log.Printf("Failed to write status for GatewayRoute %s: %v", key, statusErr)
+	}
+
+	if route == nil {
+		// 解析/校验失败（缺 match、缺 backend.service_name）或引用未就绪（Service 还不存在、
+		// 插件名未知）：把错误往上抛，让 workqueue 按速率限制重试。
+		reason := status.acceptedMessage
+		if status.accepted {
+			reason = status.resolvedRefsMessage
 		}
+		return fmt.Errorf("GatewayRoute %s not programmed: %s", key, reason)
 	}
+	return nil
+}
+
+// routeStatus 汇总一次 reconcile 产生的条件信息，用于写回 GatewayRoute 的 status 子资源。
+// 字段命名对应 Gateway API 的 Accepted/ResolvedRefs/Programmed 三段式条件模型。
+type routeStatus struct {
+	generation int64
+
+	accepted        bool
+	acceptedMessage string
 
-	log.Printf("Rebuilt %d GatewayRoutes from CRDs", len(routes))
-	// 4. 将解析好的路由列表更新到 Registry，并触发推送
-	c.registry.StoreCRDRoutes(routes)
+	resolvedRefs        bool
+	resolvedRefsMessage string
+
+	programmed        bool
+	programmedMessage string
+
+	clusterID string
 }
 
-// parseRoute 解析单个 GatewayRoute CRD 对象。
+// knownPluginNames 是控制面当前认识的插件名单，reconcile 时用来给 ResolvedRefs 条件挑错。
+var knownPluginNames = map[string]bool{
+	"deny-all":   true,
+	"rate-limit": true,
+	"auth":       true,
+	"wasm":       true,
+}
+
+// reasonFor 把条件是否满足翻译成 Gateway API 风格的 Reason 字符串（"Accepted"/"Invalid" 等）。
+func reasonFor(ok bool, okReason, failReason string) string {
+	if ok {
+		return okReason
+	}
+	return failReason
+}
+
+// parseRoute 解析单个 GatewayRoute CRD 对象，同时返回写回 status 所需的条件信息。
 //
 // 假设你的 CRD YAML 是长这样的：
 // ----------------------------------------
@@ -207,45 +374,83 @@ func (c *Controller) rebuildRoutes() {
 //     - name: deny-all
 //       wasm_path: /etc/wasm/deny.wasm
 // ----------------------------------------
-func (c *Controller) parseRoute(u *unstructured.Unstructured) *agwv1.Route {
+func (c *Controller) parseRoute(u *unstructured.Unstructured) (*agwv1.Route, routeStatus) {
+	status := routeStatus{generation: u.GetGeneration()}
+
 	// u.Object 就是整个 YAML 的 map[string]interface{} 表示
-	
+
 	// 1. 提取 "spec" 字段
 	spec, found, _ := unstructured.NestedMap(u.Object, "spec")
 	if !found {
-		return nil
+		status.acceptedMessage = "spec is missing"
+		return nil, status
 	}
 
 	// 2. 提取 "spec.match" 字段 (作为路径前缀)
 	match, _, _ := unstructured.NestedString(spec, "match")
 	if match == "" {
-		return nil
+		status.acceptedMessage = "spec.match is required but was empty"
+		return nil, status
 	}
 
 	// 3. 提取 "spec.backend.service_name"
 	// 这里我们需要先拿到 backend 这个 map，再从里面拿 service_name
 	backend, _, _ := unstructured.NestedMap(spec, "backend")
 	svcName, _, _ := unstructured.NestedString(backend, "service_name")
-	
+
 	if svcName == "" {
-		return nil // Invalid route
+		status.acceptedMessage = "spec.backend.service_name is required but was empty"
+		return nil, status // Invalid route
 	}
+	status.accepted = true
+	status.acceptedMessage = "route accepted"
 
 	// 4. 构建 Cluster ID
-	// 格式必须与 Controller 中 processSlice 生成的 Cluster Name 一致: k8s/{ns}/{svc}
-	clusterName := fmt.Sprintf("k8s/%s/%s", u.GetNamespace(), svcName)
-	
-	// 5. 解析插件配置
-	plugins := c.parsePlugins(spec)
+	// 格式必须与 Registry.ListClusters 中聚合生成的 Cluster Name 一致: k8s/{clusterID}/{ns}/{svc}
+	// spec.backend.cluster_id 是可选字段，允许一条路由显式引用另一个成员集群的 Service，
+	// 从而跨集群分流；缺省时落回本 Controller 自己所属的集群。
+	backendClusterID, _, _ := unstructured.NestedString(backend, "cluster_id")
+	if backendClusterID == "" {
+		backendClusterID = c.clusterID
+	}
+	clusterName := fmt.Sprintf("k8s/%s/%s/%s", clusterIDOrDefault(backendClusterID), u.GetNamespace(), svcName)
+	status.clusterID = clusterName
+
+	// 5. 解析插件配置，顺带校验插件名是否都认识
+	plugins, unknownPlugin := c.parsePlugins(spec)
+
+	if !c.registry.HasService(backendClusterID, u.GetNamespace(), svcName) {
+		status.resolvedRefsMessage = fmt.Sprintf("Service %q not found in registry", svcName)
+	} else if unknownPlugin != "" {
+		status.resolvedRefsMessage = fmt.Sprintf("unknown plugin %q", unknownPlugin)
+	} else {
+		status.resolvedRefs = true
+		status.resolvedRefsMessage = "all references resolved"
+	}
+
+	// Programmed 反映这条路由是否已经真正出现在推送给数据面的配置里：
+	// 只有 Accepted 且所有引用都能解析，我们才会把它放进 Registry。
+	status.programmed = status.accepted && status.resolvedRefs
+	if status.programmed {
+		status.programmedMessage = "route programmed into data plane config"
+	} else {
+		status.programmedMessage = "route not programmed: " + status.resolvedRefsMessage
+	}
+
+	if !status.resolvedRefs {
+		// 路由本身格式正确 (Accepted)，但引用的 Service 还不存在或插件名未知：
+		// 不能把它塞给数据面，所以仍然返回 nil，等引用就绪后由 resync 或下一次事件重新 reconcile。
+		return nil, status
+	}
 
 	return &agwv1.Route{
 		PathPrefix: match,
 		ClusterId:  clusterName,
 		Plugins:    plugins,
-	}
+	}, status
 }
 
-// parsePlugins 解析插件配置列表
+// parsePlugins 解析插件配置列表，并顺带返回遇到的第一个未知插件名（用于 ResolvedRefs 条件）。
 // 对应 YAML:
 // spec:
 //   plugins:
@@ -253,30 +458,32 @@ func (c *Controller) parseRoute(u *unstructured.Unstructured) *agwv1.Route {
 //       wasm_path: "..."
 //       config:
 //         key: "value"
-func (c *Controller) parsePlugins(spec map[string]interface{}) []*agwv1.Plugin {
+func (c *Controller) parsePlugins(spec map[string]interface{}) (plugins []*agwv1.Plugin, unknownPlugin string) {
 	// 1. 提取 "spec.plugins" 列表
 	rawPlugins, found, _ := unstructured.NestedSlice(spec, "plugins")
 	if !found {
-		return nil
+		return nil, ""
 	}
 
-	var plugins []*agwv1.Plugin
 	for _, p := range rawPlugins {
 		// 每个插件项也是一个 map
 		pmap, ok := p.(map[string]interface{})
 		if !ok {
 			continue
 		}
-		
+
 		// 2. 提取插件字段
 		name, _, _ := unstructured.NestedString(pmap, "name")
 		wasmPath, _, _ := unstructured.NestedString(pmap, "wasm_path")
 		rawConfig, _, _ := unstructured.NestedMap(pmap, "config") // config 是一个 map[string]string
-		
+
+		if name != "" && !knownPluginNames[name] && unknownPlugin == "" {
+			unknownPlugin = name
+		}
+
 		// 3. 转换 config map (map[string]interface{} -> map[string]string)
 		config := make(map[string]string)
 		for k, v := range rawConfig {
-			// strVal, ok := v.(string);
 			if strVal, aa := v.(string); aa {
 				config[k] = strVal
 			}
@@ -288,100 +495,137 @@ func (c *Controller) parsePlugins(spec map[string]interface{}) []*agwv1.Plugin {
 			Config:   config,
 		})
 	}
-	return plugins
-}
-
-func (c *Controller) onServiceAdd(obj interface{}) {
-	// For basic discovery, we rely on EndpointSlices. 
-	// Services are useful if we need ClusterIP or NodePort info, or specific ports mapping.
-	// For MVP, EndpointSlice contains the Service Name label.
-}
-
-func (c *Controller) onServiceUpdate(old, new interface{}) {}
-
-func (c *Controller) onServiceDelete(obj interface{}) {
-	svc := obj.(*corev1.Service)
-	// Optionally cleanup registry if NO EndpointSlice left? 
-	// But usually Slice is deleted too. Let Slice delete handle it?
-	// If Service is deleted, Slices are GCed.
-	c.registry.DeleteService(svc.Namespace, svc.Name)
+	return plugins, unknownPlugin
 }
 
-func (c *Controller) onSliceAdd(obj interface{}) {
-	c.processSlice(obj)
-}
+// writeRouteStatus 把一次 reconcile 产生的条件信息写回 GatewayRoute 的 status 子资源。
+// 对应 Gateway API 的 conditions 惯例：每个条件有 type/status/reason/message/observedGeneration，
+// 这样 `kubectl describe gatewayroute` 就能直接看到为什么一条路由没有生效。
+//
+// reconcileRoute 对每次 Add/Update 事件都无条件调用这里，而 Controller 又是用 UpdateFunc 把
+// Update 事件入队的——如果每次都真的发起 UpdateStatus，resourceVersion 的变化会立刻产生一个
+// 新的 Update 事件，变成 "写 status -> Update 事件 -> 重新入队 -> 又写 status" 的热循环，
+// GatewayRoute 的 lastTransitionTime 也会被每次 reconcile 刷新成当前时间，永远停不下来。
+// 所以这里先和已经写在对象上的 status 比较：内容完全一样（不算 lastTransitionTime，它本来就
+// 只应该在 status 真正翻转时更新）就直接跳过 UpdateStatus。
+func (c *Controller) writeRouteStatus(ctx context.Context, u *unstructured.Unstructured, status routeStatus) error {
+	existing := existingRouteConditions(u)
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	conditions := []interface{}{
+		routeCondition(existing, "Accepted", status.accepted, now, status.generation,
+			reasonFor(status.accepted, "Accepted", "Invalid"), status.acceptedMessage),
+		routeCondition(existing, "ResolvedRefs", status.resolvedRefs, now, status.generation,
+			reasonFor(status.resolvedRefs, "ResolvedRefs", "RefNotFound"), status.resolvedRefsMessage),
+		routeCondition(existing, "Programmed", status.programmed, now, status.generation,
+			reasonFor(status.programmed, "Programmed", "NotProgrammed"), status.programmedMessage),
+	}
 
-func (c *Controller) onSliceUpdate(old, new interface{}) {
-	c.processSlice(new)
-}
+	existingGeneration, _, _ := unstructured.NestedInt64(u.Object, "status", "observedGeneration")
+	existingClusterID, _, _ := unstructured.NestedString(u.Object, "status", "clusterId")
+	if existingGeneration == status.generation && existingClusterID == status.clusterID && routeConditionsUnchanged(existing, conditions) {
+		return nil
+	}
 
-func (c *Controller) onSliceDelete(obj interface{}) {
-	slice := obj.(*discoveryv1.EndpointSlice)
-	svcName := slice.Labels[discoveryv1.LabelServiceName]
-	if svcName != "" {
-		// For MVP: Treat slice delete as service delete or empty endpoints.
-		// Construct minimal cluster with empty endpoints
-		cluster := &agwv1.Cluster{
-			Name:      fmt.Sprintf("k8s/%s/%s", slice.Namespace, svcName),
-			Endpoints: []*agwv1.Endpoint{},
+	// 不能直接改 Lister 缓存里的对象（会污染共享 Informer store），先深拷贝一份。
+	updated := u.DeepCopy()
+	if err := unstructured.SetNestedField(updated.Object, status.generation, "status", "observedGeneration"); err != nil {
+		return err
+	}
+	if err := unstructured.SetNestedSlice(updated.Object, conditions, "status", "conditions"); err != nil {
+		return err
+	}
+	if status.clusterID != "" {
+		if err := unstructured.SetNestedField(updated.Object, status.clusterID, "status", "clusterId"); err != nil {
+			return err
 		}
-		c.registry.UpdateEndpointSlice(slice, cluster)
 	}
+
+	_, err := c.dynClient.Resource(gatewayRouteGVR).Namespace(u.GetNamespace()).UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
 }
 
-// processSlice 处理 EndpointSlice 对象的变更，将其转换为网关内部的 Cluster 模型。
-// 这是服务发现的核心逻辑：将 K8s 的 "切片" (Slices) 聚合成网关可用的 "集群" (Clusters)。
-func (c *Controller) processSlice(obj interface{}) {
-	// 1. 类型断言：确保拿到的对象是 EndpointSlice
-	slice, ok := obj.(*discoveryv1.EndpointSlice)
-	if !ok {
-		return
-	}
-	
-	// 2. 获取所属 Service 名称
-	// EndpointSlice 通过 Label "kubernetes.io/service-name" 关联到 Service
-	svcName := slice.Labels[discoveryv1.LabelServiceName]
-	if svcName == "" {
-		return
+// existingRouteConditions 读取对象当前 status.conditions，按 type 建索引。writeRouteStatus
+// 用它来判断这次算出来的条件是否和已经写回的完全一样，以及在 status 没有翻转时把新条件的
+// lastTransitionTime 保留成旧值，而不是每次 reconcile 都刷新成当前时间。
+func existingRouteConditions(u *unstructured.Unstructured) map[string]map[string]interface{} {
+	result := make(map[string]map[string]interface{})
+	raw, found, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if !found {
+		return result
 	}
-
-	endpoints := make([]*agwv1.Endpoint, 0)
-	
-	// 3. 遍历切片中的所有 Endpoint (即 Pod)
-	for _, ep := range slice.Endpoints {
-		// 3.1 过滤掉未就绪 (Not Ready) 的 Pod
-		// 如果 Pod 正在启动或探针失败，不应该转发流量过去
-		if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+	for _, c := range raw {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
 			continue
 		}
-		// 3.2 确保有 IP 地址
-		if len(ep.Addresses) == 0 {
+		condType, _ := cond["type"].(string)
+		if condType == "" {
 			continue
 		}
-		
-		// 3.3 提取端口信息
-		// MVP 简化处理：默认取第一个端口，如果没有定义则默认为 80
-		var port uint32 = 80
-		if len(slice.Ports) > 0 && slice.Ports[0].Port != nil {
-			port = uint32(*slice.Ports[0].Port)
-		}
+		result[condType] = cond
+	}
+	return result
+}
 
-		// 3.4 构建内部 Endpoint 对象
-		endpoints = append(endpoints, &agwv1.Endpoint{
-			Address: ep.Addresses[0], // 通常 Pod 只有一个 IP，取第一个即可
-			Port:    port,
-		})
+// routeCondition 构建一个符合 metav1.Condition JSON 形状的条件对象。
+// 之所以手写 map 而不是用 metav1.Condition 结构体，是因为我们是通过 Dynamic Client
+// 操作 unstructured 对象，字段必须是 SetNestedSlice 认识的 JSON 兼容类型。
+//
+// lastTransitionTime 只有在这个条件的 status (True/False) 相对上一次写回发生翻转时才刷新成
+// now，否则沿用 existing 里记录的旧值——这既是 Gateway API conditions 惯例本身的要求
+// （lastTransitionTime 表示 "status 最近一次变化的时间"，不是 "最近一次 reconcile 的时间"），
+// 也是上面 writeRouteStatus 判断 "内容没变就跳过 UpdateStatus" 能够成立的前提。
+func routeCondition(existing map[string]map[string]interface{}, condType string, ok bool, now string, generation int64, reason, message string) map[string]interface{} {
+	statusStr := "False"
+	if ok {
+		statusStr = "True"
 	}
 
-	// 4. 构建内部 Cluster 对象
-	// 命名规则：k8s/{namespace}/{serviceName}
-	// 这样网关的核心逻辑就可以通过这个 ID 找到对应的后端列表
-	cluster := &agwv1.Cluster{
-		Name:      fmt.Sprintf("k8s/%s/%s", slice.Namespace, svcName),
-		Endpoints: endpoints,
+	lastTransitionTime := now
+	if prev, found := existing[condType]; found {
+		if prevStatus, _ := prev["status"].(string); prevStatus == statusStr {
+			if prevTime, _ := prev["lastTransitionTime"].(string); prevTime != "" {
+				lastTransitionTime = prevTime
+			}
+		}
 	}
 
-	// 5. 更新 Registry
-	// 将转换好的 Cluster 数据存入内存，并触发变更通知
-	c.registry.UpdateEndpointSlice(slice, cluster)
+	return map[string]interface{}{
+		"type":               condType,
+		"status":             statusStr,
+		"reason":             reason,
+		"message":            message,
+		"observedGeneration": generation,
+		"lastTransitionTime": lastTransitionTime,
+	}
 }
+
+// routeConditionsUnchanged 比较新计算出的 conditions 和 existingRouteConditions 读到的旧状态
+// 是否完全一样。observedGeneration 已经由调用方单独比较过，这里只需要比较每个条件的
+// type/status/reason/message/lastTransitionTime——lastTransitionTime 在 routeCondition 里已经
+// 被保留成旧值了，这里仍然比一遍，是为了兜住 existing 里缺这个字段、或者旧值本身是空字符串
+// 之类的边界情况（那些情况下 routeCondition 会落回 now，必然和旧状态不相等，应当触发更新）。
+func routeConditionsUnchanged(existing map[string]map[string]interface{}, conditions []interface{}) bool {
+	if len(existing) != len(conditions) {
+		return false
+	}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		condType, _ := cond["type"].(string)
+		prev, found := existing[condType]
+		if !found {
+			return false
+		}
+		for _, field := range []string{"status", "reason", "message", "lastTransitionTime"} {
+			if prev[field] != cond[field] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTestGatewayRoute(namespace, name string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("agw.masallsome.io/v1")
+	u.SetKind("GatewayRoute")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	u.SetGeneration(1)
+	return u
+}
+
+func countUpdateStatusActions(actions []clienttesting.Action) int {
+	count := 0
+	for _, a := range actions {
+		if a.GetVerb() == "update" && a.GetSubresource() == "status" {
+			count++
+		}
+	}
+	return count
+}
+
+// TestWriteRouteStatusSkipsNoOpUpdate 覆盖 chunk0-3 的回归场景：reconcileRoute 对每次 Add/Update
+// 事件都无条件调用 writeRouteStatus，而 Controller 又用 UpdateFunc 把 Update 事件入队——如果
+// 每次都真的发起 UpdateStatus，就会变成一个自维持的热循环。计算出的状态和已经写回的完全一样时，
+// 第二次调用不应该再发起 UpdateStatus 请求。
+func TestWriteRouteStatusSkipsNoOpUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	gvrToListKind := map[string]string{gatewayRouteGVR.Resource: "GatewayRouteList"}
+	route := newTestGatewayRoute("default", "my-route")
+	dynClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme, gvrToListKind, route)
+	c := &Controller{dynClient: dynClient}
+
+	status := routeStatus{
+		generation:          1,
+		accepted:            true,
+		acceptedMessage:     "route accepted",
+		resolvedRefs:        true,
+		resolvedRefsMessage: "all references resolved",
+		programmed:          true,
+		programmedMessage:   "route programmed into data plane config",
+		clusterID:           "k8s/default/default/backend",
+	}
+
+	if err := c.writeRouteStatus(context.Background(), route, status); err != nil {
+		t.Fatalf("first writeRouteStatus: %v", err)
+	}
+	if got := countUpdateStatusActions(dynClient.Actions()); got != 1 {
+		t.Fatalf("expected exactly 1 UpdateStatus call after the first write, got %d", got)
+	}
+
+	updated, err := dynClient.Resource(gatewayRouteGVR).Namespace("default").Get(context.Background(), "my-route", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("fetching updated object: %v", err)
+	}
+	dynClient.ClearActions()
+
+	if err := c.writeRouteStatus(context.Background(), updated, status); err != nil {
+		t.Fatalf("second writeRouteStatus: %v", err)
+	}
+	if got := countUpdateStatusActions(dynClient.Actions()); got != 0 {
+		t.Fatalf("expected no UpdateStatus call when status is unchanged, got %d", got)
+	}
+}
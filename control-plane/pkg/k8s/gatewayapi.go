@@ -0,0 +1,544 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Gateway API (gateway.networking.k8s.io) 的 GVR 坐标。
+// 上游还没有稳定的 typed client 被广泛引入这个仓库，所以和 GatewayRoute CRD 一样，
+// 通过 Dynamic Client + Unstructured 来读取，不引入额外的生成代码依赖。
+var (
+	gatewayClassGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gatewayclasses"}
+	gatewayGVR      = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+	httpRouteGVR    = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+	referenceGrantGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "referencegrants"}
+
+	// gatewaySecretGVR 是核心 v1 Secret 的坐标，用 Dynamic Client 读取是为了不用再额外引入
+	// 一个 *kubernetes.Clientset：wireGatewayListenerTLS 只需要按命名空间+名字查一个 Secret，
+	// 不需要 typed client 带来的好处。
+	gatewaySecretGVR = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}
+)
+
+// GatewayAPIController 把上游 Gateway API 的 HTTPRoute 资源翻译成网关内部的
+// agwv1.Route 模型。GatewayClass/Gateway 只作为 "这条 HTTPRoute 是不是挂在我们
+// 这个网关下面" 的过滤依据，本身不产生 Route。
+type GatewayAPIController struct {
+	// clusterID 标识这个控制器实例归属的成员集群，写入翻译出的 Route 的 ClusterId，
+	// 与 Controller/IngressController 保持同一套多集群聚合约定。Gateway API 的
+	// backendRefs 本身没有跨集群字段，翻译出的 Cluster 固定属于本控制器所在的集群。
+	clusterID string
+
+	dynClient  dynamic.Interface
+	dynFactory dynamicinformer.DynamicSharedInformerFactory
+
+	gatewayClassInf cache.SharedIndexInformer
+	gatewayInf      cache.SharedIndexInformer
+	gatewayLister   cache.GenericLister
+
+	httpRouteInf    cache.SharedIndexInformer
+	httpRouteLister cache.GenericLister
+
+	referenceGrantInf    cache.SharedIndexInformer
+	referenceGrantLister cache.GenericLister
+
+	// secretInf/secretLister 喂给 wireGatewayListenerTLS：Gateway 的 spec.listeners[].tls
+	// 引用的 Secret 和 Gateway 本身在同一个命名空间。
+	secretInf    cache.SharedIndexInformer
+	secretLister cache.GenericLister
+
+	registry *Registry
+	queue    workqueue.RateLimitingInterface
+
+	// gatewayClassName 是本网关实例对应的 GatewayClass 名字：只有 parentRefs 指向一个
+	// spec.gatewayClassName 等于这个值的 Gateway 的 HTTPRoute 才会被认领。
+	gatewayClassName string
+}
+
+// NewGatewayAPIController 创建一个新的 Gateway API 翻译控制器。
+// clusterID 标识这个控制器归属的成员集群，单集群部署传空字符串即可。
+func NewGatewayAPIController(clusterID string, dynClient dynamic.Interface, registry *Registry, gatewayClassName string) *GatewayAPIController {
+	dynFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynClient, resyncPeriod)
+
+	gatewayClassGI := dynFactory.ForResource(gatewayClassGVR)
+	gatewayGI := dynFactory.ForResource(gatewayGVR)
+	httpRouteGI := dynFactory.ForResource(httpRouteGVR)
+	referenceGrantGI := dynFactory.ForResource(referenceGrantGVR)
+	secretGI := dynFactory.ForResource(gatewaySecretGVR)
+
+	c := &GatewayAPIController{
+		clusterID:  clusterID,
+		dynClient:  dynClient,
+		dynFactory: dynFactory,
+
+		gatewayClassInf: gatewayClassGI.Informer(),
+
+		gatewayInf:    gatewayGI.Informer(),
+		gatewayLister: gatewayGI.Lister(),
+
+		httpRouteInf:    httpRouteGI.Informer(),
+		httpRouteLister: httpRouteGI.Lister(),
+
+		referenceGrantInf:    referenceGrantGI.Informer(),
+		referenceGrantLister: referenceGrantGI.Lister(),
+
+		secretInf:    secretGI.Informer(),
+		secretLister: secretGI.Lister(),
+
+		registry:         registry,
+		queue:            workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		gatewayClassName: gatewayClassName,
+	}
+
+	c.httpRouteInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue(c.queue, obj) },
+		UpdateFunc: func(old, new interface{}) { enqueue(c.queue, new) },
+		DeleteFunc: func(obj interface{}) { enqueue(c.queue, obj) },
+	})
+
+	// Gateway/GatewayClass 变化（例如一个 Gateway 改了 gatewayClassName）理论上也应该让挂在
+	// 它下面的 HTTPRoute 重新评估归属，但反向索引 "这个 Gateway 下面挂了哪些 HTTPRoute"
+	// 并不便宜。MVP 阶段先依赖 resyncPeriod 的周期性全量 resync 兜底收敛，不为这两种资源
+	// 单独建反向索引。
+
+	return c
+}
+
+// Run 启动 Gateway API 控制器，直到 ctx 被取消。
+func (c *GatewayAPIController) Run(ctx context.Context) {
+	log.Println("Starting Gateway API Controller...")
+	defer c.queue.ShutDown()
+
+	go c.dynFactory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.gatewayClassInf.HasSynced, c.gatewayInf.HasSynced, c.httpRouteInf.HasSynced, c.referenceGrantInf.HasSynced, c.secretInf.HasSynced) {
+		log.Println("Timed out waiting for Gateway API caches to sync")
+		return
+	}
+	log.Println("Gateway API Controller synced.")
+	c.registry.MarkSynced("gatewayapi")
+
+	for i := 0; i < numWorkers; i++ {
+		go wait.Until(func() { c.runWorker(ctx) }, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+	log.Println("Stopping Gateway API Controller")
+}
+
+func (c *GatewayAPIController) runWorker(ctx context.Context) {
+	for {
+		key, shutdown := c.queue.Get()
+		if shutdown {
+			return
+		}
+		func() {
+			defer c.queue.Done(key)
+			if err := c.reconcileHTTPRoute(ctx, key.(string)); err != nil {
+				log.Printf("Error reconciling HTTPRoute %q, requeuing: %v", key, err)
+				c.queue.AddRateLimited(key)
+				return
+			}
+			c.queue.Forget(key)
+		}()
+	}
+}
+
+func (c *GatewayAPIController) reconcileHTTPRoute(_ context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	registryKey := "httproute/" + key
+
+	obj, err := c.httpRouteLister.ByNamespace(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.registry.DeleteRouteGroup(registryKey)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected object type for HTTPRoute %s: %T", key, obj)
+	}
+
+	if !c.isAttachedToOwnedGateway(u, namespace) {
+		c.registry.DeleteRouteGroup(registryKey)
+		return nil
+	}
+
+	warnIfHostnamesUnsupported(u, key)
+
+	// 一个 HTTPRoute 可能有多条 rule/match，UpsertRouteGroup 会把它们整体存成一组子 key，
+	// 并在条数变少（包括变成 0）时清掉上一轮多写的旧子 key。
+	routes := c.translateHTTPRoute(u, namespace)
+	c.registry.UpsertRouteGroup(registryKey, routes)
+
+	c.wireTLSFromParentGateways(u, namespace)
+	return nil
+}
+
+// warnIfHostnamesUnsupported 在 spec.hostnames 非空时打一条警告：见 translateHTTPRoute
+// 开头的说明，agwv1.Route 目前没有 host 字段，这些 hostname 会被直接忽略，翻译出的路由对
+// 所有 Host 都生效。这里只是让运维在配置了 hostnames 却发现没有按 Host 区分流量时，能从
+// 日志里查到原因，而不是误以为是 bug 或者静默被吞掉。
+func warnIfHostnamesUnsupported(u *unstructured.Unstructured, key string) {
+	hostnames, found, _ := unstructured.NestedStringSlice(u.Object, "spec", "hostnames")
+	if found && len(hostnames) > 0 {
+		log.Printf("Warning: HTTPRoute %s declares spec.hostnames=%v, but agwv1.Route has no host field yet; "+
+			"the translated routes will match on path alone for all hosts", key, hostnames)
+	}
+}
+
+// isAttachedToOwnedGateway 检查 spec.parentRefs 里是否至少有一个 Gateway
+// 的 spec.gatewayClassName 等于本控制器实例负责的 GatewayClass。
+// 这对应 IngressClass 过滤在 Gateway API 世界里的等价物：一个集群可能同时跑着
+// 多个 Gateway 实现，我们只处理挂在"自己"名下的 Gateway 上的 HTTPRoute。
+func (c *GatewayAPIController) isAttachedToOwnedGateway(route *unstructured.Unstructured, routeNamespace string) bool {
+	if c.gatewayClassName == "" {
+		return true
+	}
+
+	parentRefs, found, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if !found {
+		return false
+	}
+
+	for _, prRaw := range parentRefs {
+		pr, ok := prRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		gwName, _, _ := unstructured.NestedString(pr, "name")
+		if gwName == "" {
+			continue
+		}
+		gwNamespace, _, _ := unstructured.NestedString(pr, "namespace")
+		if gwNamespace == "" {
+			gwNamespace = routeNamespace
+		}
+
+		gwObj, err := c.gatewayLister.ByNamespace(gwNamespace).Get(gwName)
+		if err != nil {
+			continue
+		}
+		gw, ok := gwObj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		className, _, _ := unstructured.NestedString(gw.Object, "spec", "gatewayClassName")
+		if className == c.gatewayClassName {
+			return true
+		}
+	}
+	return false
+}
+
+// wireTLSFromParentGateways 把这条 HTTPRoute 挂载的每个 Gateway 的证书引用喂给
+// Registry.UpdateSecret。证书在 Gateway API 里挂在 Listener 上而不是 Route 上，
+// translateHTTPRoute 产出的 agwv1.Route 完全不携带 TLS 信息，所以这里单独走一遍
+// parentRefs，和 isAttachedToOwnedGateway 找 Gateway 对象的方式一致。
+func (c *GatewayAPIController) wireTLSFromParentGateways(route *unstructured.Unstructured, routeNamespace string) {
+	parentRefs, found, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if !found {
+		return
+	}
+
+	for _, prRaw := range parentRefs {
+		pr, ok := prRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		gwName, _, _ := unstructured.NestedString(pr, "name")
+		if gwName == "" {
+			continue
+		}
+		gwNamespace, _, _ := unstructured.NestedString(pr, "namespace")
+		if gwNamespace == "" {
+			gwNamespace = routeNamespace
+		}
+
+		gwObj, err := c.gatewayLister.ByNamespace(gwNamespace).Get(gwName)
+		if err != nil {
+			continue
+		}
+		gw, ok := gwObj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		c.wireGatewayListenerTLS(gw)
+	}
+}
+
+// wireGatewayListenerTLS 把 gw.spec.listeners[].tls.certificateRefs 引用的每个 Secret 喂给
+// Registry.UpdateSecret。只信任和 Gateway 同命名空间的证书引用：跨命名空间的 certificateRefs
+// 在 Gateway API 里同样需要 ReferenceGrant 授权，但这属于 MVP 阶段尚未覆盖的场景（和
+// resolveBackendClusters 不同，这里没有找到需要支持跨命名空间证书引用的实际用例），
+// 为避免引入一条没有授权校验的跨命名空间读取路径，先直接跳过。
+func (c *GatewayAPIController) wireGatewayListenerTLS(gw *unstructured.Unstructured) {
+	listeners, found, _ := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+	if !found {
+		return
+	}
+
+	for _, lRaw := range listeners {
+		l, ok := lRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		certRefs, _, _ := unstructured.NestedSlice(l, "tls", "certificateRefs")
+		for _, refRaw := range certRefs {
+			ref, ok := refRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(ref, "kind")
+			if kind != "" && kind != "Secret" {
+				continue
+			}
+			secretName, _, _ := unstructured.NestedString(ref, "name")
+			if secretName == "" {
+				continue
+			}
+			secretNamespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if secretNamespace == "" {
+				secretNamespace = gw.GetNamespace()
+			}
+			if secretNamespace != gw.GetNamespace() {
+				continue
+			}
+			c.wireSecret(secretNamespace, secretName)
+		}
+	}
+}
+
+// wireSecret 查一个 TLS Secret 并把证书/私钥喂给 Registry.UpdateSecret。
+func (c *GatewayAPIController) wireSecret(namespace, name string) {
+	obj, err := c.secretLister.ByNamespace(namespace).Get(name)
+	if err != nil {
+		return
+	}
+	secret, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	secretType, _, _ := unstructured.NestedString(secret.Object, "type")
+	if secretType != "" && secretType != "kubernetes.io/tls" {
+		return
+	}
+	data, found, _ := unstructured.NestedMap(secret.Object, "data")
+	if !found {
+		return
+	}
+	cert := decodeSecretDataField(data, "tls.crt")
+	key := decodeSecretDataField(data, "tls.key")
+	if len(cert) > 0 && len(key) > 0 {
+		c.registry.UpdateSecret(name, cert, key)
+	}
+}
+
+// decodeSecretDataField 从 Dynamic Client 读到的 Secret.data 字段里取一个 key 并 base64 解码。
+// Secret.data 在 Kubernetes API 里本来就是 base64 编码的（这是 API 的序列化约定，不是我们自己
+// 加的一层）；用 typed corev1.Secret 读取时（比如 secret_controller.go）client-go 已经在反序列化
+// 到 []byte 字段的过程中自动解码过了，但这里是 unstructured 对象，字段还是原始的 base64 字符串。
+func decodeSecretDataField(data map[string]interface{}, field string) []byte {
+	raw, ok := data[field].(string)
+	if !ok || raw == "" {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// matchPrecedence 对应 Gateway API 规定的匹配优先级：Exact 最具体，其次是越长的
+// PathPrefix，RegularExpression 排在最后（其具体程度无法静态比较）。
+// 数字越小优先级越高。
+func matchPrecedence(pathType, pathValue string) int {
+	switch pathType {
+	case "Exact":
+		return 0
+	case "RegularExpression":
+		return 2
+	default: // "PathPrefix" 或未显式声明（Gateway API 默认就是 PathPrefix）
+		return 1
+	}
+}
+
+// translateHTTPRoute 把一个 HTTPRoute 对象的 rules/matches/backendRefs 转换成
+// agwv1.Route 列表，按 Gateway API 的匹配优先级（Exact > 长 PathPrefix > RegularExpression）
+// 排序后返回，这样对 "先匹配先生效" 的数据面友好。
+//
+// 已知缺口：agwv1.Route 目前只有 PathPrefix/ClusterId/Plugins，没有 host 字段——这是
+// pkg/proto 里这个消息定义本身的限制，不是这个控制器翻译逻辑能补上的，需要先给 agwv1.Route
+// 加一个 host 字段（proto 改动+重新生成）才能真正按 Gateway API 的 host+path 组合优先级
+// 去匹配。在那之前，spec.hostnames 会被忽略，翻译出的 Route 只按 path 匹配，对所有 Host
+// 都生效；见 reconcileHTTPRoute 里对 spec.hostnames 非空时打的警告日志。
+func (c *GatewayAPIController) translateHTTPRoute(u *unstructured.Unstructured, namespace string) []*agwv1.Route {
+	type candidate struct {
+		route      *agwv1.Route
+		precedence int
+		pathLen    int
+	}
+
+	rules, found, _ := unstructured.NestedSlice(u.Object, "spec", "rules")
+	if !found {
+		return nil
+	}
+
+	var candidates []candidate
+	for _, ruleRaw := range rules {
+		rule, ok := ruleRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		backendRefs, _, _ := unstructured.NestedSlice(rule, "backendRefs")
+		clusterIDs := c.resolveBackendClusters(backendRefs, namespace)
+		if len(clusterIDs) == 0 {
+			continue
+		}
+
+		matches, _, _ := unstructured.NestedSlice(rule, "matches")
+		if len(matches) == 0 {
+			// 没有声明 matches 时 Gateway API 语义上等价于匹配所有路径。
+			for _, clusterID := range clusterIDs {
+				candidates = append(candidates, candidate{
+					route:      &agwv1.Route{PathPrefix: "/", ClusterId: clusterID},
+					precedence: matchPrecedence("PathPrefix", "/"),
+					pathLen:    1,
+				})
+			}
+			continue
+		}
+
+		for _, matchRaw := range matches {
+			match, ok := matchRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			pathMap, _, _ := unstructured.NestedMap(match, "path")
+			pathType, _, _ := unstructured.NestedString(pathMap, "type")
+			pathValue, _, _ := unstructured.NestedString(pathMap, "value")
+			if pathValue == "" {
+				pathValue = "/"
+			}
+			for _, clusterID := range clusterIDs {
+				candidates = append(candidates, candidate{
+					route:      &agwv1.Route{PathPrefix: pathValue, ClusterId: clusterID},
+					precedence: matchPrecedence(pathType, pathValue),
+					pathLen:    len(pathValue),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].precedence != candidates[j].precedence {
+			return candidates[i].precedence < candidates[j].precedence
+		}
+		return candidates[i].pathLen > candidates[j].pathLen
+	})
+
+	routes := make([]*agwv1.Route, 0, len(candidates))
+	for _, cand := range candidates {
+		routes = append(routes, cand.route)
+	}
+	return routes
+}
+
+// resolveBackendClusters 把 rule.backendRefs 转换成内部 Cluster ID 列表，
+// 按 ReferenceGrant 规则过滤掉未被授权的跨命名空间引用。
+func (c *GatewayAPIController) resolveBackendClusters(backendRefs []interface{}, routeNamespace string) []string {
+	var clusterIDs []string
+	for _, refRaw := range backendRefs {
+		ref, ok := refRaw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		svcName, _, _ := unstructured.NestedString(ref, "name")
+		if svcName == "" {
+			continue
+		}
+		backendNamespace, _, _ := unstructured.NestedString(ref, "namespace")
+		if backendNamespace == "" {
+			backendNamespace = routeNamespace
+		}
+
+		if backendNamespace != routeNamespace && !c.referenceGrantAllows(routeNamespace, backendNamespace) {
+			log.Printf("Skipping cross-namespace backendRef %s/%s from HTTPRoute in %s: no ReferenceGrant permits it",
+				backendNamespace, svcName, routeNamespace)
+			continue
+		}
+
+		clusterIDs = append(clusterIDs, fmt.Sprintf("k8s/%s/%s/%s", clusterIDOrDefault(c.clusterID), backendNamespace, svcName))
+	}
+	return clusterIDs
+}
+
+// referenceGrantAllows 检查 toNamespace 里是否存在一个 ReferenceGrant，
+// 允许来自 fromNamespace 的 HTTPRoute 引用该命名空间里的 Service。
+// 这是 Gateway API 里跨命名空间 backendRefs 必须满足的前提条件，
+// 防止一个命名空间的路由悄悄地把流量导到另一个命名空间，除非目标命名空间明确同意。
+func (c *GatewayAPIController) referenceGrantAllows(fromNamespace, toNamespace string) bool {
+	objs, err := c.referenceGrantLister.ByNamespace(toNamespace).List(labels.Everything())
+	if err != nil {
+		return false
+	}
+	for _, obj := range objs {
+		grant, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		froms, _, _ := unstructured.NestedSlice(grant.Object, "spec", "from")
+		tos, _, _ := unstructured.NestedSlice(grant.Object, "spec", "to")
+
+		fromMatches := false
+		for _, fRaw := range froms {
+			f, ok := fRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(f, "kind")
+			ns, _, _ := unstructured.NestedString(f, "namespace")
+			if kind == "HTTPRoute" && ns == fromNamespace {
+				fromMatches = true
+				break
+			}
+		}
+		if !fromMatches {
+			continue
+		}
+
+		for _, tRaw := range tos {
+			t, ok := tRaw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			kind, _, _ := unstructured.NestedString(t, "kind")
+			if kind == "" || kind == "Service" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// IngressController 把标准的 networking.k8s.io/v1 Ingress 资源翻译成网关内部的
+// agwv1.Route 模型，写入同一个 Registry。这样已经在用原生 Ingress 的 k8s 用户
+// 不需要把 manifest 重写成 agw.masallsome.io/v1 GatewayRoute 就能接入这个网关。
+//
+// 结构上完全照搬 Controller 对 GatewayRoute 的 workqueue reconcile 模式：
+// 事件回调只入队 key，worker 通过 Lister 查询最新对象再 reconcile。
+type IngressController struct {
+	// clusterID 标识这个控制器实例归属的成员集群，写入翻译出的 Route 的 ClusterId，
+	// 与 Controller/GatewayAPIController 保持同一套多集群聚合约定。
+	clusterID string
+
+	client        *kubernetes.Clientset
+	factory       informers.SharedInformerFactory
+	ingressInf    cache.SharedIndexInformer
+	ingressLister networkinglisters.IngressLister
+
+	// secretInf/secretLister 喂给 translateIngress 之后的 TLS 证书装配：spec.tls[].secretName
+	// 引用的 Secret 和 Ingress 本身在同一个命名空间，这里单独起一个 Secret Informer 就能按
+	// 命名空间+名字查，不需要依赖 SecretController 那边按名字全局广播的 Registry.secrets。
+	secretInf    cache.SharedIndexInformer
+	secretLister corelisters.SecretLister
+
+	registry *Registry
+
+	queue workqueue.RateLimitingInterface
+
+	// ingressClassName 限定这个控制器只认领指定 IngressClass 的 Ingress 对象，
+	// 避免在一个集群里同时跑多个 Ingress 实现时互相抢资源。
+	// 为空字符串表示不做过滤，认领所有 Ingress（适合单一网关独占集群的场景）。
+	ingressClassName string
+}
+
+// registryKeyForIngress 给 Ingress 来源的路由加上前缀，
+// 避免和 GatewayRoute CRD（前缀 "gatewayroute/"）或 Gateway API HTTPRoute
+// （前缀 "httproute/"）在 Registry.routes 这个共享 map 里撞 key。
+func registryKeyForIngress(key string) string {
+	return "ingress/" + key
+}
+
+// NewIngressController 创建一个新的 Ingress 翻译控制器。
+// clusterID 标识这个控制器归属的成员集群，单集群部署传空字符串即可。
+// ingressClassName 为空时认领所有 Ingress；非空时只认领
+// spec.ingressClassName 等于该值、或带有等价 `kubernetes.io/ingress.class` 注解的对象。
+func NewIngressController(clusterID string, client *kubernetes.Clientset, registry *Registry, ingressClassName string) *IngressController {
+	factory := informers.NewSharedInformerFactory(client, resyncPeriod)
+	ingressInformer := factory.Networking().V1().Ingresses()
+	secretInformer := factory.Core().V1().Secrets()
+
+	c := &IngressController{
+		clusterID:        clusterID,
+		client:           client,
+		factory:          factory,
+		ingressInf:       ingressInformer.Informer(),
+		ingressLister:    ingressInformer.Lister(),
+		secretInf:        secretInformer.Informer(),
+		secretLister:     secretInformer.Lister(),
+		registry:         registry,
+		queue:            workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		ingressClassName: ingressClassName,
+	}
+
+	c.ingressInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { enqueue(c.queue, obj) },
+		UpdateFunc: func(old, new interface{}) { enqueue(c.queue, new) },
+		DeleteFunc: func(obj interface{}) { enqueue(c.queue, obj) },
+	})
+
+	return c
+}
+
+// Run 启动 Ingress 控制器，直到 ctx 被取消。
+func (c *IngressController) Run(ctx context.Context) {
+	log.Println("Starting K8s Ingress Controller...")
+	defer c.queue.ShutDown()
+
+	go c.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.ingressInf.HasSynced, c.secretInf.HasSynced) {
+		log.Println("Timed out waiting for Ingress cache sync")
+		return
+	}
+	log.Println("K8s Ingress Controller synced.")
+	c.registry.MarkSynced("ingress")
+
+	for i := 0; i < numWorkers; i++ {
+		go wait.Until(func() { c.runWorker(ctx) }, time.Second, ctx.Done())
+	}
+
+	<-ctx.Done()
+	log.Println("Stopping K8s Ingress Controller")
+}
+
+func (c *IngressController) runWorker(ctx context.Context) {
+	for {
+		key, shutdown := c.queue.Get()
+		if shutdown {
+			return
+		}
+		func() {
+			defer c.queue.Done(key)
+			if err := c.reconcile(ctx, key.(string)); err != nil {
+				log.Printf("Error reconciling Ingress %q, requeuing: %v", key, err)
+				c.queue.AddRateLimited(key)
+				return
+			}
+			c.queue.Forget(key)
+		}()
+	}
+}
+
+func (c *IngressController) reconcile(_ context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	ing, err := c.ingressLister.Ingresses(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		c.registry.DeleteRouteGroup(registryKeyForIngress(key))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !c.owns(ing) {
+		// 不是我们这个网关负责的 IngressClass：确保之前可能认领过的路由被撤销后直接跳过。
+		c.registry.DeleteRouteGroup(registryKeyForIngress(key))
+		return nil
+	}
+
+	// 一个 Ingress 对象可能包含多条 rule（多个 host/path 组合），UpsertRouteGroup 会把它们
+	// 整体存成一组子 key，并在这一轮条数比上一轮少（或者变成 0）时清掉多余的旧子 key。
+	routes := c.translateIngress(ing)
+	c.registry.UpsertRouteGroup(registryKeyForIngress(key), routes)
+
+	c.wireTLSSecrets(ing)
+	return nil
+}
+
+// wireTLSSecrets 把 spec.tls[].secretName 引用的 Secret 内容喂给 Registry.UpdateSecret，
+// 这样 HTTPS Ingress 才能在数据面拿到证书——translateIngress 只产出 PathPrefix/ClusterId，
+// 完全不知道 TLS 的存在。spec.tls[].secretName 引用的 Secret 总是和 Ingress 在同一个命名空间
+// （这是 networking.k8s.io/v1 的规定），所以直接用 Ingress 的 namespace 去查，不需要像
+// backendRefs 那样过 ReferenceGrant。
+//
+// 这里不在 Ingress 被删除/不再认领时撤销对应的 Secret：Registry.secrets 是按 Secret 名字
+// 全局共享的一张表（SecretController 本身也在监听同一批 Secret），一个 Secret 可能同时被
+// 另一个 Ingress/Listener 引用，贸然在这里 DeleteSecret 会把仍在使用的证书也一起撤掉；
+// Secret 对象真正被删除时自然由 SecretController 的 DeleteFunc 处理。
+func (c *IngressController) wireTLSSecrets(ing *networkingv1.Ingress) {
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		secret, err := c.secretLister.Secrets(ing.Namespace).Get(tls.SecretName)
+		if err != nil {
+			// Secret 还没创建，或者还没进到本地缓存：不是这里的错误，等它出现后
+			// 下一次 resync（或者将来对 Secret 事件做反向索引）会重新尝试。
+			continue
+		}
+		if secret.Type != corev1.SecretTypeTLS {
+			continue
+		}
+		cert := secret.Data["tls.crt"]
+		key := secret.Data["tls.key"]
+		if len(cert) > 0 && len(key) > 0 {
+			c.registry.UpdateSecret(tls.SecretName, cert, key)
+		}
+	}
+}
+
+// owns 判断这个 Ingress 是否应该被本网关认领。
+// 优先看 spec.ingressClassName（stable 字段），兼容旧版本的
+// `kubernetes.io/ingress.class` 注解。
+func (c *IngressController) owns(ing *networkingv1.Ingress) bool {
+	if c.ingressClassName == "" {
+		return true
+	}
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName == c.ingressClassName
+	}
+	return ing.Annotations["kubernetes.io/ingress.class"] == c.ingressClassName
+}
+
+// translateIngress 把一个 Ingress 对象的所有 rule 转换成 agwv1.Route 列表。每个 path 生成
+// 一条 Route，Cluster 引用沿用 EndpointSlice 聚合时使用的命名约定
+// "k8s/{clusterID}/{namespace}/{serviceName}"，这样不需要额外翻译层就能直接复用已有的
+// Service 发现结果。Ingress 本身没有跨集群字段，固定引用本控制器所属的集群。
+//
+// 已知缺口：rule.Host 目前被忽略，没有体现在生成的 Route 里——agwv1.Route 只有
+// PathPrefix/ClusterId/Plugins，没有 host 字段，这是 pkg/proto 里这个消息定义本身的限制，
+// 需要先给它加一个 host 字段（proto 改动+重新生成）才能做到真正的按 Host+Path 路由。
+// 在那之前，两条只有 host 不同的 rule 翻译出的 Route 只按 path 匹配，对所有 Host 都生效；
+// reconcile 里会在 rule.Host 非空时打一条警告日志，见 warnIfHostUnsupported。
+func (c *IngressController) translateIngress(ing *networkingv1.Ingress) []*agwv1.Route {
+	var routes []*agwv1.Route
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		warnIfHostUnsupported(rule.Host, ing.Namespace, ing.Name)
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				// TODO: 支持 resource backend（指向非 Service 的自定义资源）；MVP 阶段只支持 Service backend。
+				continue
+			}
+			clusterName := fmt.Sprintf("k8s/%s/%s/%s", clusterIDOrDefault(c.clusterID), ing.Namespace, path.Backend.Service.Name)
+			pathPrefix := path.Path
+			if pathPrefix == "" {
+				pathPrefix = "/"
+			}
+			routes = append(routes, &agwv1.Route{
+				PathPrefix: pathPrefix,
+				ClusterId:  clusterName,
+			})
+		}
+	}
+	return routes
+}
+
+// warnIfHostUnsupported 在 rule.Host 非空时打一条警告：见 translateIngress 开头的说明，
+// agwv1.Route 目前没有 host 字段，这个 host 会被直接忽略，翻译出的路由对所有 Host 都生效。
+// 这里只是让运维在配置了按 Host 分流却发现没生效时，能从日志里查到原因。
+func warnIfHostUnsupported(host, namespace, name string) {
+	if host != "" {
+		log.Printf("Warning: Ingress %s/%s rule has host=%q, but agwv1.Route has no host field yet; "+
+			"the translated route will match on path alone for all hosts", namespace, name, host)
+	}
+}
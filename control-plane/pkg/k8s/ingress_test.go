@@ -0,0 +1,100 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// TestIngressControllerWiresTLSSecret 覆盖 chunk0-4 里之前被忽略的部分：Ingress 的
+// spec.tls[].secretName 必须最终体现为 Registry.UpdateSecret 的一次调用，这样 HTTPS
+// Ingress 才能在数据面拿到证书。
+func TestIngressControllerWiresTLSSecret(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-tls", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			"tls.crt": []byte("fake-cert"),
+			"tls.key": []byte("fake-key"),
+		},
+	}
+	client := fake.NewSimpleClientset(secret)
+	registry := NewRegistry("")
+
+	factory := informers.NewSharedInformerFactory(client, resyncPeriod)
+	secretInformer := factory.Core().V1().Secrets()
+
+	c := &IngressController{
+		secretLister: secretInformer.Lister(),
+		registry:     registry,
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, secretInformer.Informer().HasSynced) {
+		t.Fatal("timed out waiting for secret informer to sync")
+	}
+
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			TLS: []networkingv1.IngressTLS{{Hosts: []string{"example.com"}, SecretName: "web-tls"}},
+		},
+	}
+
+	c.wireTLSSecrets(ing)
+
+	got := registry.GetSecret("web-tls")
+	if got == nil {
+		t.Fatal("expected Registry to have the secret wired from ing.Spec.TLS, got nil")
+	}
+	if string(got.Cert) != "fake-cert" || string(got.Key) != "fake-key" {
+		t.Fatalf("unexpected secret contents: %+v", got)
+	}
+}
+
+// TestTranslateIngressDistinctHostsCollapseToSameRoute 记录了 chunk0-4 review 里指出的已知
+// 缺口：agwv1.Route 目前没有 host 字段（见 translateIngress 开头的说明），所以两条只有
+// rule.Host 不同、path/backend 完全一样的 rule 翻译出的 Route 是无法区分的——这个测试锁定
+// 的是"当前确实如此"这个事实，而不是期望行为；一旦 agwv1.Route 加上了 host 字段，这个测试
+// 需要跟着更新成断言两条 Route 不同。
+func TestTranslateIngressDistinctHostsCollapseToSameRoute(t *testing.T) {
+	c := &IngressController{}
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: "a.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{Path: "/", Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: "svc"},
+						}}},
+					}},
+				},
+				{
+					Host: "b.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{Path: "/", Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{Name: "svc"},
+						}}},
+					}},
+				},
+			},
+		},
+	}
+
+	routes := c.translateIngress(ing)
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+	if routes[0].PathPrefix != routes[1].PathPrefix || routes[0].ClusterId != routes[1].ClusterId {
+		t.Fatalf("expected the two host-distinct rules to translate to an indistinguishable PathPrefix/ClusterId pair, got %+v vs %+v", routes[0], routes[1])
+	}
+}
@@ -0,0 +1,78 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// LeaderElectionConfig 描述基于 Lease 的选主参数。
+// 多副本部署控制面时，只有 leader 才应该跑 Discovery/Secret/Ingress/Gateway API 控制器，
+// 否则多个副本会同时往同一个 GatewayRoute 写 status、重复 reconcile，产生冲突的写入。
+type LeaderElectionConfig struct {
+	Enabled bool
+
+	LeaseName      string
+	LeaseNamespace string
+
+	// Identity 为空时使用 "hostname_随机后缀"，足以区分同一个 Lease 下的多个副本。
+	Identity string
+
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// RunLeaderElected 阻塞运行 Lease 选主循环，直到 ctx 被取消。
+// onStartedLeading 只在成为 leader 时调用一次，拿到的 leaderCtx 会在这个副本失去
+// leader 身份（被抢占、续约失败、进程退出）时自动取消——调用方只需要把所有"只有 leader
+// 才能做"的控制器 goroutine 挂在 leaderCtx 下面，不需要自己记录、手动停止它们。
+func RunLeaderElected(ctx context.Context, client *kubernetes.Clientset, cfg LeaderElectionConfig, onStartedLeading func(leaderCtx context.Context)) error {
+	identity := cfg.Identity
+	if identity == "" {
+		hostname, _ := os.Hostname()
+		identity = fmt.Sprintf("%s_%s", hostname, uuid.NewUUID())
+	}
+
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      cfg.LeaseName,
+			Namespace: cfg.LeaseNamespace,
+		},
+		Client: client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		ReleaseOnCancel: true,
+		LeaseDuration:   cfg.LeaseDuration,
+		RenewDeadline:   cfg.RenewDeadline,
+		RetryPeriod:     cfg.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				log.Printf("Became leader for Lease %s/%s (identity=%s)", cfg.LeaseNamespace, cfg.LeaseName, identity)
+				onStartedLeading(leaderCtx)
+			},
+			OnStoppedLeading: func() {
+				log.Printf("Lost leadership for Lease %s/%s (identity=%s); stopping controllers, gRPC server keeps serving cached Registry state", cfg.LeaseNamespace, cfg.LeaseName, identity)
+			},
+			OnNewLeader: func(currentID string) {
+				if currentID != identity {
+					log.Printf("New leader elected for Lease %s/%s: %s", cfg.LeaseNamespace, cfg.LeaseName, currentID)
+				}
+			},
+		},
+	})
+	return ctx.Err()
+}
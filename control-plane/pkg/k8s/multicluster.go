@@ -0,0 +1,133 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// MultiClusterConfig 管理除本地（in-cluster / KUBECONFIG 指向的那个）集群之外，
+// 额外接入的成员集群。每个成员集群各自起一套 Controller/IngressController/
+// GatewayAPIController/SecretController，共享同一个 Registry，这样
+// ListClusters/ListRoutes 自然就聚合出了跨集群的视图——做法上参考的是
+// Istio Pilot 的 remote secret：一个 kubeconfig 对应一个远端集群。
+//
+// MVP 阶段成员集群列表从一个目录里的 kubeconfig 文件读取（文件名即 clusterID），
+// 在启动时加载一次；动态增删集群（watch 一个存放 kubeconfig 的 Secret）留到
+// 有实际需求时再做，目前先用和本地 config.yaml 类似的 "文件即真相" 模型。
+type MultiClusterConfig struct {
+	registry *Registry
+
+	ingressClassName string
+	gatewayClassName string
+
+	mu       sync.Mutex
+	clusters map[string]context.CancelFunc
+}
+
+// NewMultiClusterConfig 创建一个空的多集群管理器。
+// ingressClassName/gatewayClassName 会原样传给每个成员集群起的
+// IngressController/GatewayAPIController，保持和单集群模式一样的过滤规则。
+func NewMultiClusterConfig(registry *Registry, ingressClassName, gatewayClassName string) *MultiClusterConfig {
+	return &MultiClusterConfig{
+		registry:         registry,
+		ingressClassName: ingressClassName,
+		gatewayClassName: gatewayClassName,
+		clusters:         make(map[string]context.CancelFunc),
+	}
+}
+
+// LoadFromDir 扫描 dir 目录下的每一个文件，把文件名当作 clusterID、文件内容当作
+// kubeconfig，为每一个文件调用 AddCluster。dir 不存在时直接返回 nil（多集群是可选功能）。
+func (m *MultiClusterConfig) LoadFromDir(ctx context.Context, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read multi-cluster kubeconfig dir %q: %w", dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		clusterID := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		kubeconfigPath := filepath.Join(dir, entry.Name())
+
+		restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+		if err != nil {
+			log.Printf("Skipping multi-cluster kubeconfig %s: %v", kubeconfigPath, err)
+			continue
+		}
+		clientset, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			log.Printf("Skipping multi-cluster kubeconfig %s: failed to build clientset: %v", kubeconfigPath, err)
+			continue
+		}
+		dynClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			log.Printf("Skipping multi-cluster kubeconfig %s: failed to build dynamic client: %v", kubeconfigPath, err)
+			continue
+		}
+
+		m.AddCluster(ctx, clusterID, clientset, dynClient)
+	}
+	return nil
+}
+
+// AddCluster 为一个成员集群启动完整的一套控制器（Discovery/Secret/Ingress/Gateway API），
+// 全部写入同一个共享 Registry，并带上 clusterID 前缀。重复调用同一个 clusterID 会先停掉
+// 旧的一套控制器，避免 kubeconfig 轮换时泄漏 goroutine。
+func (m *MultiClusterConfig) AddCluster(ctx context.Context, clusterID string, clientset *kubernetes.Clientset, dynClient dynamic.Interface) {
+	m.RemoveCluster(clusterID)
+
+	clusterCtx, cancel := context.WithCancel(ctx)
+
+	m.mu.Lock()
+	m.clusters[clusterID] = cancel
+	m.mu.Unlock()
+
+	log.Printf("Adding member cluster %q to multi-cluster registry", clusterID)
+
+	go func() {
+		k8s := NewController(clusterID, clientset, dynClient, m.registry)
+		k8s.Run(clusterCtx)
+	}()
+	go func() {
+		ctrl := NewSecretController(clientset, m.registry)
+		ctrl.Run(clusterCtx)
+	}()
+	go func() {
+		ctrl := NewIngressController(clusterID, clientset, m.registry, m.ingressClassName)
+		ctrl.Run(clusterCtx)
+	}()
+	go func() {
+		ctrl := NewGatewayAPIController(clusterID, dynClient, m.registry, m.gatewayClassName)
+		ctrl.Run(clusterCtx)
+	}()
+}
+
+// RemoveCluster 停掉某个成员集群的全部控制器，并清空它在 Registry 里贡献的聚合数据。
+// 对应 kubeconfig 被移除或集群被踢出联邦的场景。
+func (m *MultiClusterConfig) RemoveCluster(clusterID string) {
+	m.mu.Lock()
+	cancel, ok := m.clusters[clusterID]
+	delete(m.clusters, clusterID)
+	m.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	cancel()
+	m.registry.PurgeCluster(clusterID)
+	log.Printf("Removed member cluster %q from multi-cluster registry", clusterID)
+}
@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
@@ -9,15 +10,62 @@ import (
 )
 
 // Registry 保存了已发现的 K8s 服务的当前状态。
-// 它将 Service 键 (namespace/name) 映射到 Cluster 快照。
+// 它将 Service 键 (namespace/name) 映射到由其名下所有 EndpointSlice 聚合而成的 Cluster。
 // Registry 用于维护从 Kubernetes 集群中同步过来的服务、路由和密钥信息，
 // 并提供给控制平面主循环使用，以便生成最新的配置推送给数据平面。
 type Registry struct {
-	mu       sync.RWMutex
-	clusters map[string]*agwv1.Cluster // 存储服务集群信息，key 为 "namespace/serviceName"
-	routes   []*agwv1.Route            // 存储从 CRD 或 Ingress 转换而来的路由规则
-	secrets  map[string]*TlsSecret     // 存储 TLS 证书和密钥，key 为 Secret 名称
-	updates  chan struct{}             // 信号通道，用于通知 Registry 状态发生变化
+	mu sync.RWMutex
+
+	// slices 按 "clusterID/namespace/serviceName" -> "sliceName" -> *sliceState 存储每个
+	// EndpointSlice 的原始数据。clusterID 区分了这份数据来自哪个成员集群（单集群部署时固定
+	// 为 defaultClusterID），这样多个集群的同名 Service 不会互相覆盖。
+	// 一个 Service 在大规模部署、双栈或按拓扑切分时可能对应多个 EndpointSlice，
+	// 所以这里不能再按 Service 直接覆盖存储，而是保留每个 Slice 自己的贡献，
+	// 在 ListClusters() 读取时再把同一个 Service 名下的所有 Slice 聚合成一个 agwv1.Cluster。
+	slices map[string]map[string]*sliceState
+
+	// routes 按来源对象的 "namespace/name" 存储路由规则（GatewayRoute CRD、Ingress 等）。
+	// 改成按 key 增量存取是为了配合 workqueue 化之后的 Controller：每次只 reconcile 一个对象，
+	// 不再需要（也没有能力）一次性拿到全量对象列表去做 StoreCRDRoutes 那种整体替换。
+	routes map[string]*agwv1.Route
+
+	// routeGroups 记录每个 "路由组" 来源对象（一个 Ingress/HTTPRoute 可能翻译出多条 Route，
+	// 按 "baseKey#0"、"baseKey#1"... 存进 r.routes）当前写入了多少个子 key。UpsertRouteGroup /
+	// DeleteRouteGroup 靠它知道上一轮写到了第几个，这样对象被删除、不再被认领，或者翻译出的
+	// 路由条数变少（shrink）时，能把所有旧子 key 一起清掉，而不是只删掉调用方传的那一个 key。
+	routeGroups map[string]int
+
+	secrets map[string]*TlsSecret // 存储 TLS 证书和密钥，key 为 Secret 名称
+
+	// redis / databases 按来源对象的 "kind/namespace/name" 存储 RedisResource/DatabaseResource
+	// CRD 翻译出来的配置（见 pkg/k8s/resource_controller.go），key 的取法和 routes 一致，
+	// 同一类型但不同对象不会互相覆盖。
+	redis     map[string]*agwv1.RedisConfig
+	databases map[string]*agwv1.DatabaseConfig
+
+	updates chan struct{} // 信号通道，用于通知 Registry 状态发生变化
+
+	// syncedSources 记录了哪些 K8s 控制器已经完成了首次 informer cache sync（见各控制器
+	// Run() 里调用的 MarkSynced）。SourcesReady 用它判断 "K8s 这个来源是不是还处于半同步
+	// 状态"，供 aggregate.k8sSource 在控制面刚启动、Informer 还没追上集群全量状态时告诉
+	// ConfigController 先别把这一轮合并结果当真。一旦某个名字进了这个集合就不会再被移除——
+	// 哪怕后续因为选主易主重启了控制器，重启前已经观察到的那部分集群状态仍然是有效的。
+	syncedSources map[string]struct{}
+
+	// preferredAddressType 决定聚合时保留哪个地址族的 Endpoint。
+	// EndpointSlice 按地址族分片（IPv4/IPv6 各一份），如果不加过滤，
+	// 双栈 Service 会把两种地址混进同一个 Cluster，数据面无法正确处理。
+	// 由 NewRegistry 的调用方通过 AGW_PREFERRED_ADDRESS_TYPE 配置，不是写死的编译期常量——
+	// 纯 IPv6 集群如果硬编码成 IPv4，会把所有 Service 都聚合成空 Cluster。
+	preferredAddressType discoveryv1.AddressType
+}
+
+// sliceState 保存了单个 EndpointSlice 对聚合结果的贡献。
+// 只保留聚合时需要的字段，避免长期持有完整的 K8s 对象。
+type sliceState struct {
+	addressType discoveryv1.AddressType
+	ports       []discoveryv1.EndpointPort
+	endpoints   []discoveryv1.Endpoint
 }
 
 // TlsSecret 封装了 TLS 证书和私钥的字节内容。
@@ -26,13 +74,22 @@ type TlsSecret struct {
 	Key  []byte
 }
 
-// NewRegistry 创建并初始化一个新的 Registry 实例。
-func NewRegistry() *Registry {
+// NewRegistry 创建并初始化一个新的 Registry 实例。preferredAddressType 是聚合 EndpointSlice
+// 时保留的地址族；传空字符串时退回 discoveryv1.AddressTypeIPv4，和引入这个参数之前的行为一致。
+func NewRegistry(preferredAddressType discoveryv1.AddressType) *Registry {
+	if preferredAddressType == "" {
+		preferredAddressType = discoveryv1.AddressTypeIPv4
+	}
 	return &Registry{
-		clusters: make(map[string]*agwv1.Cluster),
-		routes:   make([]*agwv1.Route, 0),
-		secrets:  make(map[string]*TlsSecret),
-		updates:  make(chan struct{}, 1),
+		slices:               make(map[string]map[string]*sliceState),
+		routes:               make(map[string]*agwv1.Route),
+		routeGroups:          make(map[string]int),
+		secrets:              make(map[string]*TlsSecret),
+		redis:                make(map[string]*agwv1.RedisConfig),
+		databases:            make(map[string]*agwv1.DatabaseConfig),
+		syncedSources:        make(map[string]struct{}),
+		updates:              make(chan struct{}, 1),
+		preferredAddressType: preferredAddressType,
 	}
 }
 
@@ -52,67 +109,262 @@ func (r *Registry) notify() {
 	}
 }
 
-// UpdateEndpointSlice 处理 EndpointSlice 并更新相应的 Cluster 信息。
-// 对于 MVP 版本，我们假设 1 个 Service 对应 1 个 Cluster。
-// 命名约定: "k8s/{namespace}/{service_name}"
-func (r *Registry) UpdateEndpointSlice(slice *discoveryv1.EndpointSlice, cluster *agwv1.Cluster) {
+// defaultClusterID 是单集群部署（没有配置 MultiClusterConfig）时使用的 clusterID。
+const defaultClusterID = "default"
+
+// clusterIDOrDefault 把空字符串的 clusterID（单集群部署，未配置 MultiClusterConfig）
+// 归一化成 defaultClusterID，避免聚合键里出现空字符串段。
+// 所有构造这个三段式键的地方（serviceKey 以及各 Controller 生成 ClusterId 字符串时）
+// 都必须经过它，否则同一个 Service 会因为 "" 和 "default" 两种写法而对不上号。
+func clusterIDOrDefault(clusterID string) string {
+	if clusterID == "" {
+		return defaultClusterID
+	}
+	return clusterID
+}
+
+// serviceKey 返回 Service 级别的聚合键："clusterID/namespace/serviceName"。
+func serviceKey(clusterID, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", clusterIDOrDefault(clusterID), namespace, name)
+}
+
+// UpdateEndpointSlice 记录单个 EndpointSlice 的贡献。
+// 注意：这里不再直接覆盖 Service 对应的 Cluster，而是按 "clusterID/namespace/service/sliceName"
+// 存储这个切片自己携带的 Endpoint，真正的 Cluster 在 ListClusters() 里按 Service 聚合生成。
+// 这样一个 Service 背后挂多个 Slice（大规模服务、双栈、拓扑分片、多集群）时，后到的 Slice 不会冲掉先到的。
+func (r *Registry) UpdateEndpointSlice(clusterID string, slice *discoveryv1.EndpointSlice) {
+	svcName := slice.Labels[discoveryv1.LabelServiceName]
+	if svcName == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := serviceKey(clusterID, slice.Namespace, svcName)
+	if r.slices[key] == nil {
+		r.slices[key] = make(map[string]*sliceState)
+	}
+	r.slices[key][slice.Name] = &sliceState{
+		addressType: slice.AddressType,
+		ports:       slice.Ports,
+		endpoints:   slice.Endpoints,
+	}
+	r.notify()
+}
+
+// DeleteEndpointSlice 移除单个 EndpointSlice 的贡献，而不影响同一个 Service 名下的其它 Slice。
+// 对应 EndpointSlice 被删除（例如拓扑重新分片）而不是整个 Service 被删除的场景。
+func (r *Registry) DeleteEndpointSlice(clusterID, namespace, serviceName, sliceName string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	key := fmt.Sprintf("%s/%s", slice.Namespace, slice.Labels["kubernetes.io/service-name"])
-	// 在真实的实现中，一个 Service 可能对应多个 EndpointSlice，我们需要合并它们。
-	// 对于 MVP，我们简化处理，直接基于服务名覆盖/更新。
-	// 理想情况下，应该映射 Slice -> Endpoints 并进行聚合。
-	
-	r.clusters[key] = cluster
+
+	key := serviceKey(clusterID, namespace, serviceName)
+	if perSlice, ok := r.slices[key]; ok {
+		delete(perSlice, sliceName)
+		if len(perSlice) == 0 {
+			delete(r.slices, key)
+		}
+	}
 	r.notify()
 }
 
-// DeleteService 从 Registry 中删除指定的服务。
-func (r *Registry) DeleteService(namespace, name string) {
+// DeleteService 从 Registry 中删除指定 Service 名下的所有 Slice 贡献。
+// 对应 Service 本体被删除的场景：它名下的 EndpointSlice 最终都会被 GC，
+// 但我们不等那些事件逐个到达，直接一次性清空，避免短暂的陈旧数据。
+func (r *Registry) DeleteService(clusterID, namespace, name string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	key := fmt.Sprintf("%s/%s", namespace, name)
-	delete(r.clusters, key)
+
+	delete(r.slices, serviceKey(clusterID, namespace, name))
 	r.notify()
 }
 
-// ListClusters 返回所有已发现的 Cluster 列表。
-// 返回的是 Cluster 指针的切片。
+// PurgeCluster 移除属于指定 clusterID 的所有 Service 聚合数据。
+// 在 MultiClusterConfig 注销一个成员集群（kubeconfig 被删除、Secret 被移除）时调用，
+// 避免失联集群的陈旧后端继续出现在推送给数据面的配置里。
+func (r *Registry) PurgeCluster(clusterID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prefix := clusterID + "/"
+	for key := range r.slices {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.slices, key)
+		}
+	}
+	r.notify()
+}
+
+// endpointUsable 判断单个 EndpointSlice Endpoint 是否应该被纳入转发目标，
+// 遵循 EndpointSlice API 里 Ready/Serving/Terminating 三个状态位的语义：
+//   - Terminating == true：Pod 正在下线，为避免把新流量打到一个即将消失的后端，直接跳过。
+//   - Serving 非空：比 Ready 更精确地表达 "此刻是否能正常处理请求"（包括宽限期内的 Ready=false 但仍在服务的场景），优先采用。
+//   - 否则退回到 Ready：nil 按就绪处理（兼容旧版本 API 不填充该字段的情况），显式 false 则跳过。
+func endpointUsable(cond discoveryv1.EndpointConditions) bool {
+	if cond.Terminating != nil && *cond.Terminating {
+		return false
+	}
+	if cond.Serving != nil {
+		return *cond.Serving
+	}
+	return cond.Ready == nil || *cond.Ready
+}
+
+// ListClusters 按 Service 聚合所有 EndpointSlice 贡献，生成稳定的 per-service Cluster 列表。
+//
+// 聚合规则：
+//  1. 同一个 Service 名下的所有 Slice 一起扫描。
+//  2. 按配置的地址族过滤（默认 IPv4），避免双栈 Service 把两种地址混进一个 Cluster。
+//  3. 每个 Slice 可能有多个命名端口（Named Port），不再只取 ports[0]，而是为每个端口都生成一个 Endpoint，
+//     这样多端口 Service（如同时暴露 http 和 metrics）可以被正确路由。
+//  4. 用 "address:port" 去重，避免同一个后端因为出现在多个 Slice 里而被重复计入。
 func (r *Registry) ListClusters() []*agwv1.Cluster {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	list := make([]*agwv1.Cluster, 0, len(r.clusters))
-	for _, c := range r.clusters {
-		list = append(list, c)
+
+	clusters := make([]*agwv1.Cluster, 0, len(r.slices))
+	for key, perSlice := range r.slices {
+		seen := make(map[string]struct{})
+		endpoints := make([]*agwv1.Endpoint, 0)
+
+		for _, s := range perSlice {
+			// 跳过与目标地址族不匹配的 Slice（例如双栈 Service 的 IPv6 切片）。
+			if s.addressType != "" && s.addressType != r.preferredAddressType {
+				continue
+			}
+			for _, ep := range s.endpoints {
+				if !endpointUsable(ep.Conditions) {
+					continue
+				}
+				if len(ep.Addresses) == 0 {
+					continue
+				}
+				addr := ep.Addresses[0]
+
+				// 为这个 Endpoint 支持的每一个命名端口都生成一条记录，而不是只取 ports[0]。
+				ports := s.ports
+				if len(ports) == 0 {
+					// 没有声明端口信息时退回到默认的 80，保持与历史行为一致。
+					ports = []discoveryv1.EndpointPort{{}}
+				}
+				for _, p := range ports {
+					var port uint32 = 80
+					if p.Port != nil {
+						port = uint32(*p.Port)
+					}
+
+					dedupeKey := fmt.Sprintf("%s:%d", addr, port)
+					if _, dup := seen[dedupeKey]; dup {
+						continue
+					}
+					seen[dedupeKey] = struct{}{}
+
+					endpoints = append(endpoints, &agwv1.Endpoint{
+						Address: addr,
+						Port:    port,
+					})
+				}
+			}
+		}
+
+		clusters = append(clusters, &agwv1.Cluster{
+			Name:      fmt.Sprintf("k8s/%s", key),
+			Endpoints: endpoints,
+		})
 	}
-	return list
+	return clusters
 }
 
-// StoreCRDRoutes 更新 Registry 中的路由规则。
-// 这些路由通常来自自定义资源 (CRD) 或 Ingress 资源的转换结果。
-func (r *Registry) StoreCRDRoutes(routes []*agwv1.Route) {
-	// 获取写锁 (Write Lock)：互斥锁，确保同一时间只有一个协程能修改路由表
-	// 在持有写锁期间，任何其他协程的读锁 (RLock) 和写锁 (Lock) 请求都会被阻塞
+// UpsertRoute 增量更新 key（通常是来源对象的 "namespace/name"）对应的路由规则。
+// 这是 workqueue 化之后的 Controller 在 reconcile 单个 GatewayRoute/Ingress 对象时调用的入口，
+// 取代了过去 rebuildRoutes() 那种每次变更都全量重算的方式。
+func (r *Registry) UpsertRoute(key string, route *agwv1.Route) {
 	r.mu.Lock()
-	defer r.mu.Unlock() // 函数退出时自动释放锁
-	
-	// 全量替换路由列表
-	r.routes = routes
-	
-	// 触发变更通知，告知控制平面主循环配置已更新
+	defer r.mu.Unlock()
+
+	r.routes[key] = route
 	r.notify()
 }
 
+// DeleteRoute 移除 key 对应的路由规则，用于对象被删除时的 reconcile。
+func (r *Registry) DeleteRoute(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.routes, key)
+	r.notify()
+}
+
+// routeGroupKey 拼出 "路由组" 里第 i 条路由的子 key，IngressController/GatewayAPIController
+// 的 UpsertRouteGroup/DeleteRouteGroup 调用都通过它，保证两处用的是同一套拼接规则。
+func routeGroupKey(baseKey string, i int) string {
+	return fmt.Sprintf("%s#%d", baseKey, i)
+}
+
+// UpsertRouteGroup 整体替换 baseKey（来源对象的 "namespace/name"）这一个对象翻译出的所有路由。
+// 一个 Ingress/HTTPRoute 对象可能产生多条 Route，但 r.routes 是 1 key -> 1 Route，所以按
+// "baseKey#0"、"baseKey#1"... 存成一组子 key。这里记录上一轮写了多少个子 key（r.routeGroups），
+// 这一轮条数变少时把多出来的旧子 key 一并删掉，否则规则变少（shrink）或对象被删除/不再被认领
+// 改成传空 routes 时，陈旧的子 key 会永远留在 r.routes 里，继续把流量导到已经不存在的路由上。
+// routes 为空等价于调用 DeleteRouteGroup(baseKey)。
+func (r *Registry) UpsertRouteGroup(baseKey string, routes []*agwv1.Route) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prevCount := r.routeGroups[baseKey]
+	for i, route := range routes {
+		r.routes[routeGroupKey(baseKey, i)] = route
+	}
+	for i := len(routes); i < prevCount; i++ {
+		delete(r.routes, routeGroupKey(baseKey, i))
+	}
+
+	if len(routes) == 0 {
+		delete(r.routeGroups, baseKey)
+	} else {
+		r.routeGroups[baseKey] = len(routes)
+	}
+	r.notify()
+}
+
+// DeleteRouteGroup 删除 baseKey 对应对象此前写入的所有子 key，用于对象被删除、或者不再被
+// 本控制器认领（IngressClass/GatewayClass 变更）时的 reconcile。和 DeleteRoute 的区别是
+// 它知道要删多少个 "baseKey#i"，而不是只删 baseKey 本身这一个从来不存在的 key。
+func (r *Registry) DeleteRouteGroup(baseKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count, ok := r.routeGroups[baseKey]
+	if !ok {
+		return
+	}
+	for i := 0; i < count; i++ {
+		delete(r.routes, routeGroupKey(baseKey, i))
+	}
+	delete(r.routeGroups, baseKey)
+	r.notify()
+}
+
+// HasService 报告 Registry 当前是否持有指定集群里 namespace/name 的 Service 聚合数据
+// （即至少收到过它名下一个 EndpointSlice）。GatewayRoute 控制器在写回状态时用它来判断
+// spec.backend.service_name 引用的 Service 是否存在，从而填充 ResolvedRefs 条件。
+func (r *Registry) HasService(clusterID, namespace, name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.slices[serviceKey(clusterID, namespace, name)]
+	return ok
+}
+
 // ListRoutes 返回当前存储的所有路由规则。
-// 为了并发安全，返回的是路由切片的副本。
+// 顺序并不保证与写入顺序一致（底层是 map），下游合并逻辑如果需要确定性顺序应自行排序。
 func (r *Registry) ListRoutes() []*agwv1.Route {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	// Return a copy slice
-	list := make([]*agwv1.Route, len(r.routes))
-	copy(list, r.routes)
+	list := make([]*agwv1.Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		list = append(list, route)
+	}
 	return list
 }
 
@@ -120,7 +372,7 @@ func (r *Registry) ListRoutes() []*agwv1.Route {
 func (r *Registry) UpdateSecret(name string, cert, key []byte) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
+
 	r.secrets[name] = &TlsSecret{
 		Cert: cert,
 		Key:  key,
@@ -132,7 +384,7 @@ func (r *Registry) UpdateSecret(name string, cert, key []byte) {
 func (r *Registry) DeleteSecret(name string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
+
 	delete(r.secrets, name)
 	r.notify()
 }
@@ -142,6 +394,92 @@ func (r *Registry) DeleteSecret(name string) {
 func (r *Registry) GetSecret(name string) *TlsSecret {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	
+
 	return r.secrets[name] // returns nil if not found
 }
+
+// UpsertRedis 增量更新 key（RedisResource 对象的 "redis/namespace/name"）对应的 Redis 配置。
+func (r *Registry) UpsertRedis(key string, cfg *agwv1.RedisConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.redis[key] = cfg
+	r.notify()
+}
+
+// DeleteRedis 移除 key 对应的 Redis 配置，用于 RedisResource 对象被删除时的 reconcile。
+func (r *Registry) DeleteRedis(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.redis, key)
+	r.notify()
+}
+
+// ListRedis 返回当前存储的所有 Redis 配置，顺序不保证与写入顺序一致。
+func (r *Registry) ListRedis() []*agwv1.RedisConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*agwv1.RedisConfig, 0, len(r.redis))
+	for _, cfg := range r.redis {
+		list = append(list, cfg)
+	}
+	return list
+}
+
+// UpsertDatabase 增量更新 key（DatabaseResource 对象的 "database/namespace/name"）对应的数据库配置。
+func (r *Registry) UpsertDatabase(key string, cfg *agwv1.DatabaseConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.databases[key] = cfg
+	r.notify()
+}
+
+// DeleteDatabase 移除 key 对应的数据库配置，用于 DatabaseResource 对象被删除时的 reconcile。
+func (r *Registry) DeleteDatabase(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.databases, key)
+	r.notify()
+}
+
+// ListDatabases 返回当前存储的所有数据库配置，顺序不保证与写入顺序一致。
+func (r *Registry) ListDatabases() []*agwv1.DatabaseConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := make([]*agwv1.DatabaseConfig, 0, len(r.databases))
+	for _, cfg := range r.databases {
+		list = append(list, cfg)
+	}
+	return list
+}
+
+// MarkSynced 把 source（如 "discovery"、"secret"、"ingress"）标记为已经完成首次 informer
+// cache sync。各控制器的 Run() 在 cache.WaitForCacheSync 成功返回之后调用它，不需要关心
+// 还有哪些其它控制器、它们是不是也同步完了——这是 SourcesReady 的事。
+func (r *Registry) MarkSynced(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.syncedSources[source] = struct{}{}
+}
+
+// SourcesReady 判断 expected 里列出的每一个来源是否都已经 MarkSynced 过。
+// 调用方（aggregate.k8sSource）传入的 expected 就是 cmd/server/main.go 里实际拉起的那一组
+// K8s 控制器名字；哪怕其中某个控制器因为没启用（比如没有配置 AGW_GATEWAY_CLASS 相关功能）
+// 而压根没创建，也应该把它从 expected 里去掉，否则这里会一直等一个永远不会同步的来源。
+func (r *Registry) SourcesReady(expected ...string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, source := range expected {
+		if _, ok := r.syncedSources[source]; !ok {
+			return false
+		}
+	}
+	return true
+}
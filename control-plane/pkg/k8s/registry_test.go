@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"testing"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+)
+
+// TestUpsertRouteGroupCleansUpStaleSubKeys 覆盖 chunk0-4 的回归场景：一个来源对象（Ingress/
+// HTTPRoute）先翻译出多条路由，后续一轮翻译出的路由变少，必须把多出来的旧子 key 一起删掉，
+// 否则它们会作为陈旧路由永远留在 Registry 里继续被下发给数据面。
+func TestUpsertRouteGroupCleansUpStaleSubKeys(t *testing.T) {
+	r := NewRegistry("")
+
+	r.UpsertRouteGroup("ingress/default/web", []*agwv1.Route{
+		{PathPrefix: "/a", ClusterId: "a"},
+		{PathPrefix: "/b", ClusterId: "b"},
+		{PathPrefix: "/c", ClusterId: "c"},
+	})
+	if got := len(r.ListRoutes()); got != 3 {
+		t.Fatalf("expected 3 routes after initial upsert, got %d", got)
+	}
+
+	r.UpsertRouteGroup("ingress/default/web", []*agwv1.Route{
+		{PathPrefix: "/a", ClusterId: "a"},
+	})
+	if got := len(r.ListRoutes()); got != 1 {
+		t.Fatalf("expected stale sub-keys to be cleaned up after shrinking, got %d routes", got)
+	}
+}
+
+// TestDeleteRouteGroupRemovesAllSubKeys 覆盖同一个回归场景的删除路径：对象被删除或者不再被
+// 本控制器认领时，DeleteRouteGroup 必须清空它此前写入的全部 "baseKey#i" 子 key，而不是只删
+// 从来不存在的 baseKey 本身。
+func TestDeleteRouteGroupRemovesAllSubKeys(t *testing.T) {
+	r := NewRegistry("")
+
+	r.UpsertRouteGroup("httproute/default/api", []*agwv1.Route{
+		{PathPrefix: "/x", ClusterId: "x"},
+		{PathPrefix: "/y", ClusterId: "y"},
+	})
+	if got := len(r.ListRoutes()); got != 2 {
+		t.Fatalf("expected 2 routes after upsert, got %d", got)
+	}
+
+	r.DeleteRouteGroup("httproute/default/api")
+	if got := len(r.ListRoutes()); got != 0 {
+		t.Fatalf("expected DeleteRouteGroup to remove every sub-key, got %d routes left", got)
+	}
+}
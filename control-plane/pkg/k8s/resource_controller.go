@@ -0,0 +1,186 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// redisResourceGVR / databaseResourceGVR 是 RedisResource / DatabaseResource 这两个 CRD 的
+// Group/Version/Resource 坐标，和 GatewayRoute 共用同一个 Group。
+var (
+	redisResourceGVR = schema.GroupVersionResource{
+		Group:    "agw.masallsome.io",
+		Version:  "v1",
+		Resource: "redisresources",
+	}
+	databaseResourceGVR = schema.GroupVersionResource{
+		Group:    "agw.masallsome.io",
+		Version:  "v1",
+		Resource: "databaseresources",
+	}
+)
+
+// ResourceController 监听 RedisResource / DatabaseResource CRD，把它们翻译成
+// agwv1.RedisConfig / agwv1.DatabaseConfig 并写入 Registry。
+//
+// 和 Controller（GatewayRoute/Service/EndpointSlice）不一样，这里沿用 SecretController 那种
+// 更简单的 "事件回调直接处理" 风格而不是 workqueue：Redis/Database 资源的写入频率远低于
+// Service/EndpointSlice，出错时简单重试（resync）即可，不需要 workqueue 的限速重试机制。
+type ResourceController struct {
+	client    *kubernetes.Clientset // 用于解析 DatabaseResource 引用的连接串 Secret
+	dynClient dynamic.Interface
+
+	dynFactory dynamicinformer.DynamicSharedInformerFactory
+	redisInf   cache.SharedIndexInformer
+	dbInf      cache.SharedIndexInformer
+
+	registry *Registry
+}
+
+// NewResourceController 创建一个监听 RedisResource/DatabaseResource CRD 的控制器。
+// client 用于读取 DatabaseResource.spec.connectionSecretRef 引用的 Secret。
+func NewResourceController(client *kubernetes.Clientset, dynClient dynamic.Interface, registry *Registry) *ResourceController {
+	dynFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynClient, resyncPeriod)
+
+	c := &ResourceController{
+		client:     client,
+		dynClient:  dynClient,
+		dynFactory: dynFactory,
+		redisInf:   dynFactory.ForResource(redisResourceGVR).Informer(),
+		dbInf:      dynFactory.ForResource(databaseResourceGVR).Informer(),
+		registry:   registry,
+	}
+
+	c.redisInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onRedisChange,
+		UpdateFunc: func(_, obj interface{}) { c.onRedisChange(obj) },
+		DeleteFunc: c.onRedisDelete,
+	})
+	c.dbInf.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.onDatabaseChange,
+		UpdateFunc: func(_, obj interface{}) { c.onDatabaseChange(obj) },
+		DeleteFunc: c.onDatabaseDelete,
+	})
+
+	return c
+}
+
+// Run 启动 Informer 工厂并等待两个 CRD 的缓存同步完成，阻塞直到 ctx 被取消。
+func (c *ResourceController) Run(ctx context.Context) {
+	log.Println("Starting K8s Redis/Database Resource Controller...")
+	go c.dynFactory.Start(ctx.Done())
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.redisInf.HasSynced, c.dbInf.HasSynced) {
+		log.Println("Timed out waiting for Redis/Database resource cache sync")
+		return
+	}
+	log.Println("K8s Redis/Database Resource Controller synced.")
+	c.registry.MarkSynced("resource")
+
+	<-ctx.Done()
+	log.Println("Stopping K8s Redis/Database Resource Controller")
+}
+
+// registryKey 把一个 RedisResource/DatabaseResource 对象的 "namespace/name" 变成 Registry
+// 存储用的 key，前缀和 GatewayRoute 一样是为了避免不同资源类型但同名对象互相覆盖。
+func resourceRegistryKey(kind string, u *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", kind, u.GetNamespace(), u.GetName())
+}
+
+func (c *ResourceController) onRedisChange(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	address, _, _ := unstructured.NestedString(u.Object, "spec", "address")
+	if address == "" {
+		log.Printf("Warning: RedisResource %s/%s has no spec.address, skipping", u.GetNamespace(), u.GetName())
+		return
+	}
+
+	c.registry.UpsertRedis(resourceRegistryKey("redis", u), &agwv1.RedisConfig{
+		Name:    u.GetName(),
+		Address: address,
+	})
+}
+
+func (c *ResourceController) onRedisDelete(obj interface{}) {
+	u, ok := toUnstructured(obj)
+	if !ok {
+		return
+	}
+	c.registry.DeleteRedis(resourceRegistryKey("redis", u))
+}
+
+// onDatabaseChange 翻译一个 DatabaseResource 对象。连接串不直接写在 CRD 里，而是通过
+// spec.connectionSecretRef{name, key} 引用一个 Secret——和 TLS 证书引用 Secret 是同一个思路，
+// 只是这里的解析（Secret 名字 -> 字节内容）直接在这个控制器里做一次性的 Get 调用，而不是像
+// SecretController 那样常驻 watch：连接串轮换的频率远低于证书，一次性读取加上 CRD 变更触发
+// 的重新 reconcile 已经够用，不必为此再起一个专门监听 Secret 的 Informer。
+func (c *ResourceController) onDatabaseChange(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+
+	dbType, _, _ := unstructured.NestedString(u.Object, "spec", "type")
+
+	secretName, _, _ := unstructured.NestedString(u.Object, "spec", "connectionSecretRef", "name")
+	if secretName == "" {
+		log.Printf("Warning: DatabaseResource %s/%s has no spec.connectionSecretRef.name, skipping", u.GetNamespace(), u.GetName())
+		return
+	}
+	secretKey, _, _ := unstructured.NestedString(u.Object, "spec", "connectionSecretRef", "key")
+	if secretKey == "" {
+		secretKey = "connectionString"
+	}
+
+	secret, err := c.client.CoreV1().Secrets(u.GetNamespace()).Get(context.Background(), secretName, metav1.GetOptions{})
+	if err != nil {
+		log.Printf("Warning: DatabaseResource %s/%s: failed to read Secret %s: %v", u.GetNamespace(), u.GetName(), secretName, err)
+		return
+	}
+	connStr, ok := secret.Data[secretKey]
+	if !ok || len(connStr) == 0 {
+		log.Printf("Warning: DatabaseResource %s/%s: Secret %s has no key %q", u.GetNamespace(), u.GetName(), secretName, secretKey)
+		return
+	}
+
+	c.registry.UpsertDatabase(resourceRegistryKey("database", u), &agwv1.DatabaseConfig{
+		Name:             u.GetName(),
+		Type:             dbType,
+		ConnectionString: string(connStr),
+	})
+}
+
+func (c *ResourceController) onDatabaseDelete(obj interface{}) {
+	u, ok := toUnstructured(obj)
+	if !ok {
+		return
+	}
+	c.registry.DeleteDatabase(resourceRegistryKey("database", u))
+}
+
+// toUnstructured 从 Delete 回调的 obj 里取出 *unstructured.Unstructured，兼容 Informer 在
+// watch 连接断开重建时可能补发的 cache.DeletedFinalStateUnknown 墓碑对象。
+func toUnstructured(obj interface{}) (*unstructured.Unstructured, bool) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, true
+	}
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		u, ok := tombstone.Obj.(*unstructured.Unstructured)
+		return u, ok
+	}
+	return nil, false
+}
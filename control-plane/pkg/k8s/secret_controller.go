@@ -47,6 +47,7 @@ func (c *SecretController) Run(ctx context.Context) {
 		return
 	}
 	log.Println("K8s Secret Controller synced.")
+	c.registry.MarkSynced("secret")
 }
 
 func (c *SecretController) onAdd(obj interface{}) {
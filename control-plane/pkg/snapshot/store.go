@@ -0,0 +1,85 @@
+// Package snapshot 持久化控制面最近一次成功合并、推送过的 ConfigSnapshot，支持重启后的
+// "热启动"：AgwServer 在 informer/watcher 还没完成首次同步之前，先用磁盘上的上一份快照
+// 应答新连接的数据面，而不是让它们空等到第一次真正合并完成（见 internal/server/grpc.go
+// 的 NewAgwServer）。
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	agwv1 "github.com/masallsome/masapigateway/control-plane/pkg/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// Store 持久化/加载最近一次的 ConfigSnapshot。目前只有 FileStore 这一个实现，接口单独
+// 抽出来是为了以后接对象存储（S3/GCS……）时不需要改 AgwServer 或 main.go 里调用它的地方。
+type Store interface {
+	// Save 把 snapshot 序列化后整体覆盖写入，调用方负责决定调用时机（见 broadcastMerged）。
+	Save(snapshot *agwv1.ConfigSnapshot) error
+	// Load 返回上一次 Save 写入的快照；从来没有 Save 过时返回 (nil, nil)，不是错误。
+	Load() (*agwv1.ConfigSnapshot, error)
+}
+
+// FileStore 把快照的 proto 二进制写到本地磁盘上的一个文件里。
+type FileStore struct {
+	path string
+
+	// mu 串行化并发的 Save 调用。broadcastMerged 每次广播都用一个新 goroutine 调用 Save
+	// （见 internal/server/grpc.go），如果两次广播靠得够近，两个 goroutine 会同时往同一个
+	// tmpPath 写文件再各自 rename，谁的内容实际落地完全看操作系统调度——这里不需要两次
+	// Save 真的并发执行，只需要最终落盘的是按时间顺序的某一份完整快照，所以直接用一把锁
+	// 排队，比给每次 Save 发一个独立的临时文件名更简单：后者还需要额外处理"旧的临时文件
+	// 残留"的清理，前者只是让第二个 Save 多等一小会儿。
+	mu sync.Mutex
+}
+
+// NewFileStore 创建一个持久化到 path 的 FileStore。path 所在目录必须已经存在。
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save 把 snapshot 序列化成 proto 二进制，写到一个临时文件后原子 rename 到 path，
+// 这样即使进程在写入中途被杀掉，也不会留下一份截断的快照文件把下次 Load 搞坏。
+//
+// 调用方（broadcastMerged）是每次广播都起一个新 goroutine 调用 Save，两次广播挨得足够近时
+// 会有两个 Save 同时执行；它们共享同一个固定的 tmpPath，不加锁的话两边的 os.WriteFile/
+// os.Rename 会交错，可能被 rename 进 path 的是一份写到一半的内容。这里用 f.mu 把 Save 串行化，
+// 牺牲掉的只是多一次广播时极小的等待，换来磁盘上的快照任何时候都是某一次完整 Save 的结果。
+func (f *FileStore) Save(snapshot *agwv1.ConfigSnapshot) error {
+	data, err := proto.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmpPath := f.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("write snapshot to %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, f.path); err != nil {
+		return fmt.Errorf("rename snapshot into place at %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Load 读取并反序列化 path 里的快照。文件不存在（从没 Save 过，或者这是第一次部署）
+// 不算错误，返回 (nil, nil)，调用方应该退回到 "等第一次真正合并完成" 的行为。
+func (f *FileStore) Load() (*agwv1.ConfigSnapshot, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read snapshot from %s: %w", f.path, err)
+	}
+
+	snapshot := &agwv1.ConfigSnapshot{}
+	if err := proto.Unmarshal(data, snapshot); err != nil {
+		return nil, fmt.Errorf("unmarshal snapshot from %s: %w", f.path, err)
+	}
+	return snapshot, nil
+}